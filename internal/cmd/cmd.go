@@ -2,19 +2,27 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"go.uber.org/zap/zapcore"
-	"inet.af/netaddr"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/go-logr/zapr"
 	"github.com/peterbourgon/ff/v3"
+	"github.com/peterbourgon/ff/v3/ffyaml"
 	"github.com/postfinance/flash"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -24,13 +32,39 @@ import (
 
 //nolint:gochecknoglobals //this vars are set on build by goreleaser
 var (
-	commit = "12345678"
-	ref    = "refs/refname"
+	commit  = "12345678"
+	ref     = "refs/refname"
+	version = ""
 )
 
-// Run will start the controller with the default settings
+// buildVersion returns the version set on build, or the tag name of a tag ref
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+
+	if strings.HasPrefix(ref, "refs/tags/") {
+		return strings.TrimPrefix(ref, "refs/tags/")
+	}
+
+	return "devel"
+}
+
+// Run will start the controller with the default settings, or run the validate subcommand
 func Run() int {
-	config := prepareCmdlineConfig()
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		return Validate(os.Args[2:], os.Stdout)
+	}
+
+	config, err := NewConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		fmt.Printf("unable to parse args/envs, exiting. error message: %v", err)
+
+		return 2
+	}
+
 	_, mgr, errorCode := CreateControllerManager(config)
 
 	if errorCode != 0 {
@@ -38,6 +72,21 @@ func Run() int {
 	}
 
 	z := mgr.GetLogger()
+
+	if config.TracingEndpoint != "" {
+		shutdownTracing, err := setupTracing(context.Background(), config.TracingEndpoint)
+		if err != nil {
+			z.Error(err, "unable to set up tracing")
+			return 10
+		}
+
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				z.Error(err, "unable to flush the pending trace spans")
+			}
+		}()
+	}
+
 	z.V(1).Info("starting controller-runtime manager")
 
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -55,56 +104,98 @@ func CreateControllerManager(config *controller.Config) (
 	code int,
 ) {
 	// logger initialization
-	flashLogger := flash.New()
-	if config.LogLevel < -5 || config.LogLevel > 10 {
-		flashLogger.Fatal(fmt.Errorf("log level should be between -5 and 10 (included)"))
+	var flashOpts []flash.Option
+
+	// flash picks the console encoder when attached to a terminal, json otherwise. an invalid log
+	// format, reported by the configuration validation, falls back to this default
+	switch config.LogFormat {
+	case "console":
+		flashOpts = append(flashOpts, flash.WithEncoder(flash.Console))
+	case "json":
+		flashOpts = append(flashOpts, flash.WithEncoder(flash.JSON))
+	}
+
+	flashLogger := flash.New(flashOpts...)
+
+	// validated before the log level gets inverted
+	err := config.Validate()
+	if err != nil {
+		zapr.NewLogger(flashLogger.Desugar()).V(-5).Info(fmt.Sprintf("%v, exiting", err))
+
+		return nil, nil, 10
 	}
 
 	csrController = &controller.CertificateSigningRequestReconciler{
-		Config: *config,
+		Config:  *config,
+		Version: buildVersion(),
 	}
 
 	config.LogLevel *= -1 // we inverse the level for the logging behavior between zap and logr.Logger to match
 	flashLogger.SetLevel(zapcore.Level(config.LogLevel))
 	z := zapr.NewLogger(flashLogger.Desugar())
 
-	z.V(0).Info("Kubelet-CSR-Approver controller starting.", "commit", commit, "ref", ref)
+	z.V(0).Info("Kubelet-CSR-Approver controller starting.", "commit", commit, "ref", ref, "version", buildVersion())
+	controller.SetBuildInfo(commit, ref, buildVersion())
 
-	if config.RegexStr == "" {
-		z.V(-5).Info("the provider-spefic regex must be specified, exiting")
-
-		return nil, nil, 10
-	}
+	// the provider regex of the referenced ConfigMap is read before being compiled and self-tested
+	var regexConfigMapVersion string
 
-	csrController.ProviderRegexp = regexp.MustCompile(config.RegexStr).MatchString
-
-	// IP Prefixes parsing and IPSet construction
-	var setBuilder netaddr.IPSetBuilder
-
-	for _, ipPrefix := range strings.Split(config.IPPrefixesStr, ",") {
-		ipPref, err := netaddr.ParseIPPrefix(ipPrefix)
+	if config.RegexConfigMapRef != "" {
+		regexConfigMapVersion, err = applyRegexConfigMap(context.Background(), clientset.NewForConfigOrDie(config.K8sConfig), config)
 		if err != nil {
-			z.V(-5).Info(fmt.Sprintf("Unable to parse IP prefix: %s, exiting", ipPrefix))
+			z.V(-5).Info(fmt.Sprintf("%v, exiting", err))
 
 			return nil, nil, 10
 		}
 
-		setBuilder.AddPrefix(ipPref)
+		csrController.RegexStr = config.RegexStr
 	}
 
-	var err error
-	csrController.ProviderIPSet, err = setBuilder.IPSet()
+	// the configuration has been validated, parsing it can't fail anymore
+	setupReconciler(csrController)
 
-	if err != nil {
-		z.V(-5).Info("Unable to build the Set of valid IP addresses, exiting")
+	if err = regexSelfTest(config, func(err error) { z.Error(err, "Ignoring the failed regex self-test") }); err != nil {
+		z.V(-5).Info(fmt.Sprintf("%v, exiting", err))
 
 		return nil, nil, 10
 	}
 
+	// the clientset and the manager clients share the client-side rate limits of the rest config
+	if config.ClientQPS > 0 {
+		config.K8sConfig.QPS = config.ClientQPS
+	}
+
+	if config.ClientBurst > 0 {
+		config.K8sConfig.Burst = config.ClientBurst
+	}
+
+	// the manager falls back to its 30s default as well when ShutdownGracePeriod is not set
+	var gracefulShutdownTimeout *time.Duration
+	if config.ShutdownGracePeriod > 0 {
+		gracefulShutdownTimeout = &config.ShutdownGracePeriod
+	}
+
+	// the TLS servers replace the plaintext metrics and health probe servers of the manager
+	metricsTLS := config.MetricsTLSCertFile != "" && config.MetricsAddr != "0"
+	probeTLS := config.ProbeTLSCertFile != "" && config.ProbeAddr != "0"
+
+	metricsAddr, probeAddr := config.MetricsAddr, config.ProbeAddr
+	if metricsTLS {
+		metricsAddr = "0"
+	}
+
+	if probeTLS {
+		probeAddr = "0"
+	}
+
 	ctrl.SetLogger(z)
 	mgr, err = ctrl.NewManager(config.K8sConfig, ctrl.Options{
-		MetricsBindAddress:     config.MetricsAddr,
-		HealthProbeBindAddress: config.ProbeAddr,
+		MetricsBindAddress:      metricsAddr,
+		HealthProbeBindAddress:  probeAddr,
+		LeaderElection:          config.EnableLeaderElection,
+		LeaderElectionID:        config.LeaderElectionID,
+		LeaderElectionNamespace: config.LeaderElectionNS,
+		GracefulShutdownTimeout: gracefulShutdownTimeout,
 	})
 
 	if err != nil {
@@ -116,6 +207,7 @@ func CreateControllerManager(config *controller.Config) (
 	csrController.ClientSet = clientset.NewForConfigOrDie(config.K8sConfig)
 	csrController.Client = mgr.GetClient()
 	csrController.Scheme = mgr.GetScheme()
+	csrController.EventRecorder = mgr.GetEventRecorderFor("kubelet-csr-approver")
 
 	if err = csrController.SetupWithManager(mgr); err != nil {
 		z.Error(err, "unable to create controller", "controller", "CertificateSigningRequest")
@@ -123,25 +215,247 @@ func CreateControllerManager(config *controller.Config) (
 		return nil, nil, 10
 	}
 
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		z.Error(err, "unable to set up health check")
+	if config.AuditLogPath != "" {
+		if csrController.AuditLog, err = controller.NewAuditLog(config.AuditLogPath, z.WithName("audit-log")); err != nil {
+			z.Error(err, "unable to open the audit log file", "path", config.AuditLogPath)
+
+			return nil, nil, 10
+		}
+
+		if err = mgr.Add(csrController.AuditLog); err != nil {
+			z.Error(err, "unable to set up the audit log reopening on SIGHUP")
+
+			return nil, nil, 10
+		}
+	}
+
+	if config.PendingCSRsRefreshInterval > 0 {
+		if err = mgr.Add(&controller.PendingCSRsGauge{
+			Reconciler: csrController,
+			Interval:   config.PendingCSRsRefreshInterval,
+			Log:        z.WithName("pending-csrs-gauge"),
+		}); err != nil {
+			z.Error(err, "unable to set up the pending CSRs gauge")
+
+			return nil, nil, 10
+		}
+	}
+
+	if config.GCApprovedAfter > 0 {
+		if err = mgr.Add(&controller.ApprovedCSRsCollector{
+			Reconciler: csrController,
+			Interval:   controller.DefaultGCInterval,
+			Log:        z.WithName("approved-csrs-gc"),
+		}); err != nil {
+			z.Error(err, "unable to set up the approved CSRs garbage collection")
+
+			return nil, nil, 10
+		}
+	}
+
+	if config.WatchConfig {
+		if err = mgr.Add(&configWatcher{
+			path:   config.ConfigFile,
+			reload: func() error { return reloadProviderRules(csrController, os.Args[1:], z) },
+			log:    z.WithName("config-watcher"),
+		}); err != nil {
+			z.Error(err, "unable to set up the config file watcher")
+
+			return nil, nil, 10
+		}
+	}
+
+	if config.RegexConfigMapInterval > 0 {
+		if err = mgr.Add(&regexConfigMapWatcher{
+			config:          config,
+			clientSet:       csrController.ClientSet,
+			interval:        config.RegexConfigMapInterval,
+			resourceVersion: regexConfigMapVersion,
+			reload:          func() error { return reloadProviderRules(csrController, os.Args[1:], z) },
+			log:             z.WithName("regex-configmap-watcher"),
+		}); err != nil {
+			z.Error(err, "unable to set up the provider regex ConfigMap watcher")
+
+			return nil, nil, 10
+		}
+	}
+
+	metricsHandlers := map[string]http.Handler{
+		"/config": http.HandlerFunc(csrController.ConfigHandler),
+		"/resync": http.HandlerFunc(csrController.ResyncHandler),
+	}
+
+	if metricsTLS {
+		err = mgr.Add(&tlsServer{
+			addr:     config.MetricsAddr,
+			certFile: config.MetricsTLSCertFile,
+			keyFile:  config.MetricsTLSKeyFile,
+			handler:  metricsMux(metricsHandlers),
+			log:      z.WithName("metrics-server"),
+		})
+	} else {
+		for path, handler := range metricsHandlers {
+			if err = mgr.AddMetricsExtraHandler(path, handler); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		z.Error(err, "unable to set up the metrics endpoints")
 
 		return nil, nil, 10
 	}
 
+	healthzChecks := map[string]healthz.Checker{"healthz": healthz.Ping}
+	readyzChecks := map[string]healthz.Checker{"apiserver": csrController.APIServerCheck}
+
+	if probeTLS {
+		if err := mgr.Add(&tlsServer{
+			addr:     config.ProbeAddr,
+			certFile: config.ProbeTLSCertFile,
+			keyFile:  config.ProbeTLSKeyFile,
+			handler:  probesMux(healthzChecks, readyzChecks),
+			log:      z.WithName("health-probe-server"),
+		}); err != nil {
+			z.Error(err, "unable to set up the health probe server")
+
+			return nil, nil, 10
+		}
+
+		return csrController, mgr, 0
+	}
+
+	for name, check := range healthzChecks {
+		if err := mgr.AddHealthzCheck(name, check); err != nil {
+			z.Error(err, "unable to set up health check")
+
+			return nil, nil, 10
+		}
+	}
+
+	for name, check := range readyzChecks {
+		if err := mgr.AddReadyzCheck(name, check); err != nil {
+			z.Error(err, "unable to set up ready check")
+
+			return nil, nil, 10
+		}
+	}
+
 	return csrController, mgr, 0
 }
 
-func prepareCmdlineConfig() *controller.Config {
-	fs := flag.NewFlagSet("kubelet-csr-approver", flag.ExitOnError)
+// setupReconciler compiles the validated configuration of the reconciler into its rules, selectors
+// and policies
+func setupReconciler(csrController *controller.CertificateSigningRequestReconciler) {
+	config := &csrController.Config
+
+	if config.DNSCacheTTL > 0 {
+		csrController.DNSResolver = controller.NewCachingResolver(config.DNSResolver, config.DNSCacheTTL)
+	}
+
+	rules, _ := controller.CompileProviderRules(config)
+	csrController.SetProviderRules(rules)
+	csrController.DenialMessageTmpl, _ = controller.ParseDenialMessageTemplate(config.DenialMessageTemplate)
+
+	if config.NodeNameAllowList != "" {
+		csrController.NodeNameAllowSet = make(map[string]struct{})
+
+		for _, nodeName := range strings.Split(config.NodeNameAllowList, ",") {
+			if nodeName = strings.TrimSpace(nodeName); nodeName != "" {
+				csrController.NodeNameAllowSet[nodeName] = struct{}{}
+			}
+		}
+	}
+
+	if config.ApprovalWindowsStr != "" {
+		csrController.ApprovalWindows, _ = controller.ParseApprovalWindows(config.ApprovalWindowsStr)
+	}
+
+	if config.NodeLabelSelector != "" {
+		csrController.NodeSelector, _ = labels.Parse(config.NodeLabelSelector)
+	}
+
+	if config.PriorityNodeLabelSelector != "" {
+		csrController.PriorityNodeSelector, _ = labels.Parse(config.PriorityNodeLabelSelector)
+	}
+
+	if config.CELPolicy != "" {
+		csrController.CELPolicyProgram, _ = controller.CompileCELPolicy(config.CELPolicy)
+	}
+}
+
+// ParseConfig parses the command line arguments (without the program name), the environment
+// variables and the config file into a controller configuration, which is validated by
+// CreateControllerManager. unlike the CLI, it returns an error instead of exiting. the
+// Kubernetes client configuration is left unset, see NewConfig.
+func ParseConfig(args []string) (*controller.Config, error) {
+	fs := flag.NewFlagSet("kubelet-csr-approver", flag.ContinueOnError)
+	buildConfig := registerFlags(fs)
+
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+
+	return buildConfig(), nil
+}
+
+// NewConfig parses the configuration like ParseConfig, and loads the Kubernetes client
+// configuration (from the kubeconfig or the in-cluster service account)
+func NewConfig(args []string) (*controller.Config, error) {
+	config, err := ParseConfig(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Kubeconfig == "" && config.KubeContext == "" {
+		config.K8sConfig, err = ctrl.GetConfig()
+	} else {
+		config.K8sConfig, err = kubeconfigRestConfig(config.Kubeconfig, config.KubeContext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the Kubernetes client configuration: %w", err)
+	}
+
+	return config, nil
+}
+
+// kubeconfigRestConfig loads the client configuration of the given kubeconfig context (the current
+// context when empty). without path, the kubeconfig is looked up like kubectl does
+func kubeconfigRestConfig(path, kubeContext string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+
+	// the KUBECONFIG environment variable, which also sets this flag, may hold a list of paths
+	if paths := filepath.SplitList(path); len(paths) > 1 {
+		loadingRules.Precedence = paths
+	} else {
+		loadingRules.ExplicitPath = path
+	}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext}).ClientConfig()
+}
+
+// registerFlags defines the command line flags on fs. the returned function builds
+// the controller configuration out of the flag values, once fs has been parsed
+func registerFlags(fs *flag.FlagSet) func() *controller.Config {
+	var additionalRegexStrs stringSliceFlag
+
+	fs.Var(&additionalRegexStrs, "additional-provider-regex",
+		"additional provider-specified regex to validate CSR SAN names against. can be repeated, "+
+			"a SAN name is valid as soon as it matches the provider-regex or one of the additional regexes")
 
 	var (
-		logLevel               = fs.Int("level", 0, "level ranges from -5 (Fatal) to 10 (Verbose)")
-		metricsAddr            = fs.String("metrics-bind-address", ":8080", "address the metric endpoint binds to.")
-		probeAddr              = fs.String("health-probe-bind-address", ":8081", "address the probe endpoint binds to.")
-		regexStr               = fs.String("provider-regex", ".*", "provider-specified regex to validate CSR SAN names against. accepts everything unless specified")
-		maxSec                 = fs.Int("max-expiration-sec", 367*24*3600, "maximum seconds a CSR can request a cerficate for. defaults to 367 days")
-		bypassDNSResolution    = fs.Bool("bypass-dns-resolution", false, "set this parameter to true to bypass DNS resolution checks")
+		logLevel            = fs.Int("level", 0, "level ranges from -5 (Fatal) to 10 (Verbose)")
+		logFormat           = fs.String("log-format", "", "log encoding, either console or json. defaults to json unless attached to a terminal")
+		metricsAddr         = fs.String("metrics-bind-address", ":8080", "address the metric endpoint binds to.")
+		probeAddr           = fs.String("health-probe-bind-address", ":8081", "address the probe endpoint binds to.")
+		regexStr            = fs.String("provider-regex", ".*", "provider-specified regex to validate CSR SAN names against. accepts everything unless specified")
+		maxSec              = fs.Int("max-expiration-sec", 367*24*3600, "maximum seconds a CSR can request a cerficate for. defaults to 367 days")
+		minSec              = fs.Int("min-expiration-sec", 0, "minimum seconds a CSR can request a cerficate for. defaults to 0")
+		bypassDNSResolution = fs.Bool("bypass-dns-resolution", false,
+			"set this parameter to true to bypass DNS resolution checks. mutually exclusive with -use-reverse-dns")
 		bypassHostnameCheck    = fs.Bool("bypass-hostname-check", false, "set this parameter to true to ignore mismatching DNS name and hostname")
 		ignoreNonSystemNodeCsr = fs.Bool("ignore-non-system-node", false, "set this parameter to true to ignore CSR for subjects different than system:node")
 		allowedDNSNames        = fs.Int("allowed-dns-names", 1, "number of DNS SAN names allowed in a certificate request. defaults to 1")
@@ -150,40 +464,435 @@ func prepareCmdlineConfig() *controller.Config {
 			left unspecified, all IPv4/v6 are allowed. example prefix definition:
 			192.168.0.0/16,fc00/7`,
 		)
+		enableClientCSR = fs.Bool("enable-client-csr-approval", false,
+			"set this parameter to true to also approve kube-apiserver-client-kubelet CSRs (kubelet TLS bootstrap)")
+		denyInsteadOfSkip = fs.Bool("deny-instead-of-skip", false,
+			"set this parameter to true to deny the CSRs that cannot be validated (e.g. unparseable request) instead of leaving them Pending")
+		dnsResolutionTimeout = fs.Duration("dns-resolution-timeout", controller.DefaultDNSResolutionTimeout,
+			"maximum time given to the DNS resolution of the SAN DNS names. CSRs whose resolution times out are processed again")
+		dnsServerAddress = fs.String("dns-server-address", "",
+			"address (host:port) of the DNS server used for the resolution checks. uses the system resolver unless specified")
+		dnsCacheTTL = fs.Duration("dns-cache-ttl", 30*time.Second,
+			"duration during which successful DNS lookups are cached. set it to 0 to disable the cache")
+		verifyNodeIPAddresses = fs.Bool("verify-node-ip-addresses", false,
+			"set this parameter to true to require the SAN IP addresses to be listed in the status of the requesting Node object")
+		verifyNodeDNSNames = fs.Bool("verify-node-dns-names", false,
+			"set this parameter to true to require the SAN DNS names to match the hostname (or name) of the requesting Node object")
+		verifyCloudInstanceID = fs.Bool("verify-cloud-instance-id", false,
+			"set this parameter to true to require a SAN DNS name or URI to match the instance ID of the requesting Node spec.providerID")
+		allowPodCIDRIPs = fs.Bool("allow-pod-cidr-ips", false,
+			"set this parameter to true to also allow the SAN IP addresses part of the podCIDRs of the requesting Node")
+		allowZonedIPv6 = fs.Bool("allow-zoned-ipv6", false,
+			"set this parameter to true to compare the resolved and Node IPv6 addresses with a zone (e.g. fe80::1%eth0) without it")
+		annotateDecisions = fs.Bool("annotate-decisions", false,
+			"set this parameter to true to record the reason, the controller version and the time of the decisions "+
+				"in the csr-approver/reason, csr-approver/version and csr-approver/decided-at CSR annotations")
+		verifyRequestorAccess = fs.Bool("verify-requestor-access", false,
+			"set this parameter to true to deny the kubelet-serving CSRs whose authenticated requestor isn't in the system:nodes group, "+
+				"or isn't allowed to create CSRs anymore according to a SubjectAccessReview. a TokenReview isn't possible, "+
+				"since the CSRs record the authenticated identity of the requestor but not its token")
+		requireNodeReady = fs.Bool("require-node-ready", false,
+			"set this parameter to true to only approve CSRs of existing and Ready nodes. CSRs of not-yet-Ready nodes are processed again")
+		nodeLabelSelector = fs.String("node-label-selector", "",
+			"label selector (e.g. pool=workers) restricting the nodes whose CSRs are processed. CSRs of other nodes are left Pending")
+		dnsRegexStr = fs.String("dns-regex", "",
+			"regex to validate the CSR SAN DNS names against. when specified, it overrides the provider regex(es) for DNS names")
+		shadowRegexStr = fs.String("shadow-provider-regex", "",
+			"regex the CSR SAN DNS names are evaluated against alongside the provider regex. mismatches are only logged and counted")
+		regexSelfTestFile = fs.String("regex-self-test-file", "",
+			"path of a YAML file listing sample SAN DNS names the provider regexes must (match) or must not (no-match) match. "+
+				"the controller refuses to start when the regexes disagree with any of them")
+		regexSelfTestWarnOnly = fs.Bool("regex-self-test-warn-only", false,
+			"set this parameter to true to only log the samples of the regex-self-test-file the provider regexes disagree with")
+		enableLeaderElection = fs.Bool("leader-elect", false,
+			"set this parameter to true to enable leader election, ensuring only one replica processes the CSRs")
+		leaderElectionID = fs.String("leader-election-id", "kubelet-csr-approver", "name of the lease used for the leader election")
+		leaderElectionNS = fs.String("leader-election-namespace", "",
+			"namespace of the lease used for the leader election. defaults to the namespace the controller runs in")
+		keyAlgorithmsStr = fs.String("allowed-key-algorithms", "",
+			"comma separated list of the public key algorithms (RSA, ECDSA, Ed25519) allowed in CSRs. all are allowed unless specified")
+		ipv4PrefixesStr = fs.String("provider-ipv4-prefixes", "",
+			"comma separated IPv4 prefixes that CSR IPv4 addresses shall fall into. overrides provider-ip-prefixes for IPv4 when specified")
+		ipv6PrefixesStr = fs.String("provider-ipv6-prefixes", "",
+			"comma separated IPv6 prefixes that CSR IPv6 addresses shall fall into. overrides provider-ip-prefixes for IPv6 when specified")
+		deniedIPPrefixesStr = fs.String("denied-ip-prefixes", "",
+			"comma separated IP prefixes carved out of the provider-ip-prefixes (e.g. a management subnet). "+
+				"CSR IP addresses shall not fall into them")
+		allowedIPAddresses = fs.Int("allowed-ip-addresses", 10, "number of IP SAN addresses allowed in a certificate request. defaults to 10")
+		maxTotalSANs       = fs.Int("max-total-sans", 0,
+			"maximum number of SAN entries (DNS names, IP addresses, URIs and email addresses altogether) allowed in a "+
+				"kubelet-serving certificate request, on top of the per-type limits. 0 disables it")
+		minRSAKeySize    = fs.Int("min-rsa-key-size", 2048, "minimum size, in bits, of the RSA keys of the CSRs")
+		allowedUsagesStr = fs.String("allowed-usages", "digital signature,key encipherment,server auth",
+			"comma separated list of the key usages a kubelet-serving CSR is allowed to request")
+		emitEvents    = fs.Bool("emit-events", true, "set this parameter to false to stop emitting Kubernetes Events on the processed CSRs")
+		useReverseDNS = fs.Bool("use-reverse-dns", false,
+			"set this parameter to true to verify the SAN DNS names through a reverse (PTR) lookup of the SAN IP addresses, "+
+				"instead of a forward lookup of the DNS names. mutually exclusive with -bypass-dns-resolution")
+		dryRun = fs.Bool("dry-run", false,
+			"set this parameter to true to only log the decisions taken on the CSRs, without approving or denying them")
+		allowWildcardDNS = fs.Bool("allow-wildcard-dns", false,
+			"set this parameter to true to allow SAN DNS names with a leading wildcard label (e.g. *.node1.cluster.local). "+
+				"wildcard SAN DNS names are denied otherwise")
+		approvalMessage       = fs.String("approval-message", "", "message of the Approved condition set on the approved CSRs")
+		denialMessageTemplate = fs.String("denial-message-template", "",
+			"text/template of the message of the Denied condition set on the denied CSRs, e.g. "+
+				"'{{.CSRName}} denied: {{.Reason}}, see https://wiki.example.com/csr'. {{.NodeName}} is also available")
+		metricsTLSCertFile = fs.String("metrics-tls-cert-file", "",
+			"PEM certificate file the metrics endpoint serves HTTPS with, along with metrics-tls-key-file. plaintext unless specified")
+		metricsTLSKeyFile = fs.String("metrics-tls-key-file", "",
+			"PEM private key file of the metrics-tls-cert-file")
+		probeTLSCertFile = fs.String("health-probe-tls-cert-file", "",
+			"PEM certificate file the probe endpoint serves HTTPS with, along with health-probe-tls-key-file. plaintext unless specified")
+		probeTLSKeyFile = fs.String("health-probe-tls-key-file", "",
+			"PEM private key file of the health-probe-tls-cert-file")
+		maxPendingAge = fs.Duration("max-pending-age", 0,
+			"age (since their creation) after which the Pending CSRs are denied as stale. 0 disables the limit")
+		maxConcurrentReconciles = fs.Int("max-concurrent-reconciles", 1,
+			"number of CSRs validated in parallel, e.g. when the DNS lookups dominate the latency during large rollouts")
+		maxRetries = fs.Int("max-retries", 0,
+			"number of consecutive transient failures (e.g. API server or DNS unavailability) after which a CSR is left Pending "+
+				"until its next update. 0 means unlimited")
+		nodeNameAllowList = fs.String("node-name-allow-list", "",
+			"comma separated list of node names whose SAN DNS names aren't checked against the provider regex. "+
+				"the other verifications still apply")
+		requireSystemNodesOrg = fs.Bool("require-system-nodes-org", false,
+			"set this parameter to true to deny the kubelet-serving CSRs whose subject Organization isn't exactly system:nodes")
+		requireAllDNSResolve = fs.Bool("require-all-dns-resolve", false,
+			"set this parameter to true to resolve every SAN DNS name, even with use-reverse-dns or require-fqdn-and-shortname, "+
+				"and deny the CSRs with a name which doesn't resolve within the provider IP prefixes")
+		approvalConditionType = fs.String("approval-condition-type", string(certificatesv1.CertificateApproved),
+			"type of the condition set on the approved CSRs. a custom type (e.g. example.com/Validated) is set through the status "+
+				"subresource instead of approving the CSRs, leaving the approval to another tool")
+		startupWarmupDelay = fs.Duration("startup-warmup-delay", 0,
+			"delay following the controller start during which the CSRs relying on the Node objects (e.g. node-label-selector, "+
+				"verify-node-ip-addresses) are deferred, for the informer cache to be populated. 0 disables it")
+		dnsMismatchWarnOnly = fs.Bool("dns-mismatch-warn-only", false,
+			"set this parameter to true to only log and count the SAN IP addresses (or DNS names, with use-reverse-dns) "+
+				"not matching the DNS records, instead of denying the CSRs")
+		allowedDNSSuffixesStr = fs.String("allowed-dns-suffixes", "",
+			"comma separated list of DNS suffixes (e.g. .prod.example.com) the SAN DNS names are allowed to end with, "+
+				"as an alternative to the provider regex")
+		allowedOrganizationsStr = fs.String("allowed-organizations", "",
+			"comma separated list of the organizations allowed in the CSRs subject, on top of the required system:nodes. "+
+				"when unset, the subject Organization must be exactly system:nodes")
+		organizationMatchMode = fs.String("organization-match-mode", controller.OrganizationMatchSubset,
+			"how the subject organizations are compared with the allowed-organizations: subset (every organization must be allowed) "+
+				"or superset (every allowed organization must be present)")
+		gcApprovedAfter = fs.Duration("gc-approved-after", 0,
+			"age (since their creation) after which the issued CSRs approved by the controller are deleted. 0 disables the deletion")
+		verifyRequestorIdentity = fs.Bool("verify-requestor-identity", false,
+			"set this parameter to true to deny the kubelet-client CSRs requested by a node for another node's identity")
+		tracingEndpoint = fs.String("tracing-endpoint", "",
+			"OTLP/HTTP endpoint the reconcile traces are exported to (e.g. http://otel-collector:4318). tracing is disabled when empty")
+		requireFQDNAndShortname = fs.Bool("require-fqdn-and-shortname", false,
+			"set this parameter to true to require the SAN DNS names to be exactly the node short name and its FQDN. "+
+				"supersedes allowed-dns-names")
+		watchConfig = fs.Bool("watch-config", false,
+			"set this parameter to true to reload the provider regexes and IP prefixes whenever the config file changes")
+		approvalWindowsStr = fs.String("approval-windows", "",
+			"semicolon separated list of the time windows (local time of the controller) CSRs are decided in, "+
+				"e.g. 'Mon-Fri 08:00-17:00;Sat 10:00-12:00'. outside of them, CSRs are left Pending. CSRs are decided at any time when empty")
+		validateCommonNameFormat = fs.Bool("validate-common-name-format", false,
+			"set this parameter to true to deny the CSRs whose CommonName isn't system:node:<nodename>, "+
+				"with a node name made of lowercase alphanumerical characters, '-' and '.'")
+		priorityNodeLabelSelector = fs.String("priority-node-label-selector", "",
+			"label selector of the nodes (e.g. control-plane nodes) whose CSRs bypass the max-approvals-per-minute rate limit, "+
+				"and are processed again sooner when left Pending")
+		rejectUnexpectedSANTypes = fs.Bool("reject-unexpected-san-types", false,
+			"set this parameter to true to deny the kubelet-serving CSRs whose SAN contains URIs or email addresses")
+		rejectDuplicateSANs = fs.Bool("reject-duplicate-sans", false,
+			"set this parameter to true to deny the CSRs listing the same DNS name or IP address more than once")
+		requireExpirationSeconds = fs.Bool("require-expiration-seconds", false,
+			"set this parameter to true to deny the CSRs without spec.expirationSeconds, whose certificate duration is the signer default")
+		rejectSpecialIPs = fs.Bool("reject-special-ips", false,
+			"set this parameter to true to deny the CSRs with a loopback, link-local, multicast or unspecified SAN IP address, "+
+				"even within the provider-ip-prefixes")
+		decisionWebhookURL = fs.String("decision-webhook-url", "",
+			"URL of an external webhook POSTed the CSRs which passed the built-in checks, whose verdict approves or denies them")
+		decisionWebhookTimeout = fs.Duration("decision-webhook-timeout", controller.DefaultDecisionWebhookTimeout,
+			"maximum time given to the decision webhook to respond")
+		webhookFailurePolicy = fs.String("webhook-failure-policy", controller.WebhookFailurePolicyFail,
+			"what happens to a CSR when the decision webhook fails: Fail leaves it Pending and retries, Ignore approves it")
+		celPolicy = fs.String("cel-policy", "",
+			"CEL expression evaluated against the kubelet-serving CSRs, which are denied unless it evaluates to true. "+
+				"e.g. csr.dnsNames.size() == csr.ipAddresses.size()")
+		perNodeMetrics = fs.Bool("per-node-metrics", true,
+			"label the csr_approver_last_decision_timestamp metric with the node name. disable it to bound the cardinality on large clusters")
+		relaxedRenewalMode = fs.Bool("relaxed-renewal-mode", false,
+			"set this parameter to true to skip the DNS resolution of the CSRs of the nodes which already exist and are Ready, "+
+				"i.e. of the certificate renewals, while keeping it for the initial CSRs")
+		shutdownGracePeriod = fs.Duration("shutdown-grace-period", controller.DefaultShutdownGracePeriod,
+			"time given to the in-flight CSR reconciliations to complete once the controller is stopping, e.g. on SIGTERM")
+		overridesConfigMap = fs.String("overrides-configmap", "",
+			"<namespace>/<name> of a ConfigMap overriding some of the parameters for the CSRs of the nodes matching a label selector")
+		regexConfigMapRef = fs.String("provider-regex-configmap", "",
+			"<namespace>/<name>/<key> of the ConfigMap key holding the provider regex, read at startup instead of the "+
+				"provider-regex. the controller refuses to start when the key is missing")
+		regexConfigMapInterval = fs.Duration("provider-regex-configmap-interval", 0,
+			"interval at which the provider-regex-configmap is read again, to reload the provider regex without a restart. "+
+				"0 disables it")
+		clientQPS = fs.Float64("client-qps", 20,
+			"maximum sustained rate of the requests to the Kubernetes API server. higher values speed up the CSR storms, "+
+				"at the expense of the API server load")
+		clientBurst = fs.Int("client-burst", 30,
+			"maximum burst of requests to the Kubernetes API server, above client-qps")
+		kubeconfig = fs.String("kubeconfig", "",
+			"path of the kubeconfig file used to connect to the cluster. defaults to the in-cluster configuration, or the default kubeconfig")
+		kubeContext = fs.String("context", "",
+			"kubeconfig context used to connect to the cluster. defaults to the current context")
+		pendingCSRsRefreshInterval = fs.Duration("pending-csrs-refresh-interval", 30*time.Second,
+			"interval at which the csr_approver_pending_csrs gauge is refreshed. 0 disables the gauge")
+		resyncToken = fs.String("resync-token", "",
+			"bearer token of the POST requests to the /resync endpoint of the metrics server, which enqueues all the Pending CSRs. "+
+				"the endpoint is disabled when empty")
+		pendingRequeueInterval = fs.Duration("pending-requeue-interval", controller.DefaultPendingRequeueInterval,
+			"delay after which the CSRs intentionally left Pending (e.g. because their Node isn't Ready yet) are processed again")
+		requeueJitter = fs.Float64("requeue-jitter", controller.DefaultRequeueJitter,
+			"share of the pending-requeue-interval (e.g. 0.2 for ±20%) by which each requeue of the Pending CSRs is randomly "+
+				"shortened or lengthened, spreading their processing over time. 0 disables it")
+		requireResolvedIPInPrefix = fs.Bool("require-resolved-ip-in-prefix", false,
+			"set this parameter to true to also resolve the SAN DNS names when use-reverse-dns is set, and deny the CSRs whose "+
+				"DNS names resolve outside of the provider IP prefixes. always verified without use-reverse-dns")
+		signerNames = fs.String("signer-name", certificatesv1.KubeletServingSignerName,
+			"comma separated list of the signers of the kubelet serving CSRs processed by the controller, "+
+				"e.g. for a custom signer of a downstream distribution or during a signer migration")
+		dnsNameNodeAnnotation = fs.String("dns-name-node-annotation", "",
+			"key of a Node annotation listing (comma separated) SAN DNS names allowed for this node although not prefixed "+
+				"by the node name. they must still match the provider regex, the kubelets being able to annotate their Node")
+		nodeAddressAnnotation = fs.String("node-address-annotation", "",
+			"key of a Node annotation listing (comma separated) IP addresses allowed for this node, e.g. behind a NAT, "+
+				"on top of the Node addresses. the addresses outside of the provider IP prefixes must be part of node-address-prefixes")
+		nodeAddressPrefixesStr = fs.String("node-address-prefixes", "",
+			"comma separated list of the IP prefixes the addresses of the node-address-annotation may belong to, "+
+				"on top of the provider IP prefixes. the annotation being writable by the kubelets, keep them narrow")
+		loadBalancerIPAnnotation = fs.String("load-balancer-ip-annotation", "",
+			"key of a Node annotation listing (comma separated) the IP addresses of the load balancer fronting this node, "+
+				"accepted by verify-node-ip-addresses on top of the Node addresses")
+		nodeExistenceGracePeriod = fs.Duration("node-existence-grace-period", 0,
+			"duration following the CSR creation during which a missing Node object leaves the CSR Pending, "+
+				"when verify-node-ip-addresses, verify-node-dns-names or require-node-ready is set. the CSR is denied afterwards")
+		auditLogPath = fs.String("audit-log-path", "",
+			"path of the file every CSR decision is appended to as a JSON line. the file is reopened on SIGHUP")
+		maxApprovalsPerMinute = fs.Int("max-approvals-per-minute", 0,
+			"maximum number of CSRs approved per minute, the CSRs above the limit are approved later. 0 means unlimited")
 	)
 
-	err := ff.Parse(fs, os.Args[1:], ff.WithEnvVars())
-	if err != nil {
-		fmt.Printf("unable to parse args/envs, exiting. error message: %v", err)
+	configFile := fs.String("config", "", "path of a YAML config file whose keys are the flag names (e.g. provider-regex). "+
+		"flags and environment variables take precedence over the file values")
+	fs.String("env-var-prefix", DefaultEnvVarPrefix, "prefix of the environment variables the flags are read from "+
+		"(e.g. KCA_PROVIDER_REGEX). the unprefixed variables are still read when the prefixed ones are unset. command line only")
+
+	return func() *controller.Config {
+		config := controller.Config{
+			LogLevel:               *logLevel,
+			LogFormat:              *logFormat,
+			MetricsAddr:            *metricsAddr,
+			ProbeAddr:              *probeAddr,
+			MetricsTLSCertFile:     *metricsTLSCertFile,
+			MetricsTLSKeyFile:      *metricsTLSKeyFile,
+			ProbeTLSCertFile:       *probeTLSCertFile,
+			ProbeTLSKeyFile:        *probeTLSKeyFile,
+			EnableLeaderElection:   *enableLeaderElection,
+			LeaderElectionID:       *leaderElectionID,
+			LeaderElectionNS:       *leaderElectionNS,
+			RegexStr:               *regexStr,
+			AdditionalRegexStrs:    additionalRegexStrs,
+			DNSRegexStr:            *dnsRegexStr,
+			ShadowRegexStr:         *shadowRegexStr,
+			NodeNameAllowList:      *nodeNameAllowList,
+			IPPrefixesStr:          *ipPrefixesStr,
+			IPv4PrefixesStr:        *ipv4PrefixesStr,
+			IPv6PrefixesStr:        *ipv6PrefixesStr,
+			DeniedIPPrefixesStr:    *deniedIPPrefixesStr,
+			BypassDNSResolution:    *bypassDNSResolution,
+			UseReverseDNS:          *useReverseDNS,
+			DNSResolutionTimeout:   *dnsResolutionTimeout,
+			DNSCacheTTL:            *dnsCacheTTL,
+			BypassHostnameCheck:    *bypassHostnameCheck,
+			AllowWildcardDNS:       *allowWildcardDNS,
+			IgnoreNonSystemNodeCsr: *ignoreNonSystemNodeCsr,
+			RequireSystemNodesOrg:  *requireSystemNodesOrg,
+			DNSNameNodeAnnotation:  *dnsNameNodeAnnotation,
+			MaxExpirationSeconds:   int32(*maxSec),
+			MinExpirationSeconds:   int32(*minSec),
+			AllowedDNSNames:        *allowedDNSNames,
+			AllowedIPAddresses:     *allowedIPAddresses,
+
+			EnableClientCSRApproval: *enableClientCSR,
+			VerifyRequestorIdentity: *verifyRequestorIdentity,
+			RequireFQDNAndShortname: *requireFQDNAndShortname,
+			DenyInsteadOfSkip:       *denyInsteadOfSkip,
+			VerifyNodeIPAddresses:   *verifyNodeIPAddresses,
+			VerifyNodeDNSNames:      *verifyNodeDNSNames,
+			RequireNodeReady:        *requireNodeReady,
+			NodeLabelSelector:       *nodeLabelSelector,
+			EmitEvents:              *emitEvents,
+			ApprovalMessage:         *approvalMessage,
+			DenialMessageTemplate:   *denialMessageTemplate,
+			DryRun:                  *dryRun,
+			MaxRetries:              *maxRetries,
+			PendingRequeueInterval:  *pendingRequeueInterval,
+			ResyncToken:             *resyncToken,
+			TracingEndpoint:         *tracingEndpoint,
+			ConfigFile:              *configFile,
+			WatchConfig:             *watchConfig,
+			ApprovalWindowsStr:      *approvalWindowsStr,
+			MaxApprovalsPerMinute:   *maxApprovalsPerMinute,
+			AuditLogPath:            *auditLogPath,
+			MinRSAKeySize:           *minRSAKeySize,
+
+			ValidateCommonNameFormat:   *validateCommonNameFormat,
+			NodeExistenceGracePeriod:   *nodeExistenceGracePeriod,
+			RequireResolvedIPInPrefix:  *requireResolvedIPInPrefix,
+			PriorityNodeLabelSelector:  *priorityNodeLabelSelector,
+			PendingCSRsRefreshInterval: *pendingCSRsRefreshInterval,
+			Kubeconfig:                 *kubeconfig,
+			KubeContext:                *kubeContext,
+			RejectDuplicateSANs:        *rejectDuplicateSANs,
+			DecisionWebhookURL:         *decisionWebhookURL,
+			DecisionWebhookTimeout:     *decisionWebhookTimeout,
+			WebhookFailurePolicy:       *webhookFailurePolicy,
+			CELPolicy:                  *celPolicy,
+			PerNodeMetrics:             *perNodeMetrics,
+			RelaxedRenewalMode:         *relaxedRenewalMode,
+			ShutdownGracePeriod:        *shutdownGracePeriod,
+			RejectSpecialIPs:           *rejectSpecialIPs,
+			OverridesConfigMap:         *overridesConfigMap,
+			ClientQPS:                  float32(*clientQPS),
+			ClientBurst:                *clientBurst,
+			RequireExpirationSeconds:   *requireExpirationSeconds,
+			VerifyCloudInstanceID:      *verifyCloudInstanceID,
+			AllowPodCIDRIPs:            *allowPodCIDRIPs,
+			MaxPendingAge:              *maxPendingAge,
+			AllowZonedIPv6:             *allowZonedIPv6,
+			VerifyRequestorAccess:      *verifyRequestorAccess,
+			AnnotateDecisions:          *annotateDecisions,
+			RegexSelfTestFile:          *regexSelfTestFile,
+			RegexSelfTestWarnOnly:      *regexSelfTestWarnOnly,
+			OrganizationMatchMode:      *organizationMatchMode,
+			GCApprovedAfter:            *gcApprovedAfter,
+			RequireAllDNSResolve:       *requireAllDNSResolve,
+			MaxConcurrentReconciles:    *maxConcurrentReconciles,
+			RejectUnexpectedSANTypes:   *rejectUnexpectedSANTypes,
+			NodeAddressAnnotation:      *nodeAddressAnnotation,
+			NodeAddressPrefixesStr:     *nodeAddressPrefixesStr,
+			DNSMismatchWarnOnly:        *dnsMismatchWarnOnly,
+			StartupWarmupDelay:         *startupWarmupDelay,
+			LoadBalancerIPAnnotation:   *loadBalancerIPAnnotation,
+			ApprovalConditionType:      *approvalConditionType,
+			RequeueJitter:              *requeueJitter,
+			MaxTotalSANs:               *maxTotalSANs,
+			RegexConfigMapRef:          *regexConfigMapRef,
+			RegexConfigMapInterval:     *regexConfigMapInterval,
+		}
+
+		if *keyAlgorithmsStr != "" {
+			config.AllowedKeyAlgorithms = strings.Split(*keyAlgorithmsStr, ",")
+		}
+
+		if *allowedDNSSuffixesStr != "" {
+			config.AllowedDNSSuffixes = strings.Split(*allowedDNSSuffixesStr, ",")
+		}
+
+		if *signerNames != "" {
+			config.SignerNames = strings.Split(*signerNames, ",")
+		}
+
+		if *allowedOrganizationsStr != "" {
+			config.AllowedOrganizations = strings.Split(*allowedOrganizationsStr, ",")
+		}
+
+		for _, usage := range strings.Split(*allowedUsagesStr, ",") {
+			config.AllowedUsages = append(config.AllowedUsages, certificatesv1.KeyUsage(strings.TrimSpace(usage)))
+		}
+
+		config.DNSResolver = net.DefaultResolver
+		if *dnsServerAddress != "" {
+			config.DNSResolver = newDNSServerResolver(*dnsServerAddress)
+		}
 
-		os.Exit(2)
+		return &config
 	}
+}
 
-	if *maxSec < 0 || *maxSec > 367*24*3600 {
-		fmt.Print("the maximum expiration seconds cannot be lower than 0 nor greater than 367 days")
+// DefaultEnvVarPrefix prefixes the environment variables the flags are read from, to avoid
+// collisions with the other variables of the pod
+const DefaultEnvVarPrefix = "KCA"
 
-		os.Exit(2)
+// parseFlags parses the command line arguments, the environment variables (prefixed, then
+// unprefixed) and the config file, in decreasing order of precedence
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	// the arguments are parsed first, for the env-var-prefix to be known
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
 
-	if *allowedDNSNames < 1 || *allowedDNSNames > 1000 {
-		fmt.Print("the number of allowed DNS names must be at least 1 and no more than 1000")
+	prefix := fs.Lookup("env-var-prefix").Value.String()
+	if err := setUnprefixedEnvVars(fs, prefix); err != nil {
+		return err
 	}
 
-	config := controller.Config{
-		LogLevel:               *logLevel,
-		MetricsAddr:            *metricsAddr,
-		ProbeAddr:              *probeAddr,
-		RegexStr:               *regexStr,
-		IPPrefixesStr:          *ipPrefixesStr,
-		BypassDNSResolution:    *bypassDNSResolution,
-		BypassHostnameCheck:    *bypassHostnameCheck,
-		IgnoreNonSystemNodeCsr: *ignoreNonSystemNodeCsr,
-		MaxExpirationSeconds:   int32(*maxSec),
-		AllowedDNSNames:        *allowedDNSNames,
+	return ff.Parse(fs, nil,
+		ff.WithEnvVarPrefix(prefix),
+		ff.WithConfigFileFlag("config"),
+		ff.WithConfigFileParser(ffyaml.Parser),
+	)
+}
+
+// setUnprefixedEnvVars sets the flags left unset by the command line arguments from their unprefixed
+// environment variable (e.g. PROVIDER_REGEX), unless the prefixed one is set. the unprefixed variables
+// were the only ones read before the prefix was introduced
+func setUnprefixedEnvVars(fs *flag.FlagSet, prefix string) error {
+	if prefix == "" {
+		return nil
 	}
 
-	config.DNSResolver = net.DefaultResolver
-	config.K8sConfig = ctrl.GetConfigOrDie()
+	setByArgs := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setByArgs[f.Name] = true })
+
+	var err error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", "/", "_").Replace(f.Name))
+		if err != nil || setByArgs[f.Name] || os.Getenv(prefix+"_"+key) != "" {
+			return
+		}
+
+		if value := os.Getenv(key); value != "" {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("error setting flag %q from env var %q: %w", f.Name, key, setErr)
+			}
+		}
+	})
+
+	return err
+}
+
+// newDNSServerResolver returns a resolver sending all its queries to the given DNS server.
+// the port defaults to 53 when the address doesn't specify it
+func newDNSServerResolver(address string) *net.Resolver {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// stringSliceFlag is a flag.Value accumulating the values of a repeated flag
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, " ")
+}
 
-	return &config
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
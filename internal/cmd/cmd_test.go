@@ -0,0 +1,147 @@
+package cmd_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/cmd"
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr bool
+		check   func(t *testing.T, config *controller.Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, config *controller.Config) {
+				assert.Equal(t, ".*", config.RegexStr)
+				assert.Equal(t, int32(367*24*3600), config.MaxExpirationSeconds)
+			},
+		},
+		{
+			name: "flag",
+			args: []string{"--provider-regex=^flag$"},
+			check: func(t *testing.T, config *controller.Config) {
+				assert.Equal(t, "^flag$", config.RegexStr)
+			},
+		},
+		{
+			name: "prefixed environment variable",
+			env:  map[string]string{"KCA_PROVIDER_REGEX": "^prefixed$", "KCA_MAX_EXPIRATION_SEC": "3600"},
+			check: func(t *testing.T, config *controller.Config) {
+				assert.Equal(t, "^prefixed$", config.RegexStr)
+				assert.Equal(t, int32(3600), config.MaxExpirationSeconds)
+			},
+		},
+		{
+			name: "unprefixed environment variable fallback",
+			env:  map[string]string{"PROVIDER_REGEX": "^unprefixed$"},
+			check: func(t *testing.T, config *controller.Config) {
+				assert.Equal(t, "^unprefixed$", config.RegexStr)
+			},
+		},
+		{
+			name: "prefixed environment variable over the unprefixed one",
+			env:  map[string]string{"KCA_PROVIDER_REGEX": "^prefixed$", "PROVIDER_REGEX": "^unprefixed$"},
+			check: func(t *testing.T, config *controller.Config) {
+				assert.Equal(t, "^prefixed$", config.RegexStr)
+			},
+		},
+		{
+			name: "flag over the environment variables",
+			args: []string{"--provider-regex=^flag$"},
+			env:  map[string]string{"KCA_PROVIDER_REGEX": "^prefixed$", "PROVIDER_REGEX": "^unprefixed$"},
+			check: func(t *testing.T, config *controller.Config) {
+				assert.Equal(t, "^flag$", config.RegexStr)
+			},
+		},
+		{
+			name: "custom environment variable prefix",
+			args: []string{"--env-var-prefix=CSR"},
+			env:  map[string]string{"CSR_PROVIDER_REGEX": "^custom$", "KCA_PROVIDER_REGEX": "^prefixed$"},
+			check: func(t *testing.T, config *controller.Config) {
+				assert.Equal(t, "^custom$", config.RegexStr)
+			},
+		},
+		{name: "unknown flag", args: []string{"--no-such-flag"}, wantErr: true},
+		{name: "invalid flag value", args: []string{"--allowed-dns-names=many"}, wantErr: true},
+		{name: "invalid environment variable value", env: map[string]string{"KCA_ALLOWED_DNS_NAMES": "many"}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			config, err := cmd.ParseConfig(tc.args)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			tc.check(t, config)
+		})
+	}
+}
+
+// writeCSRFile writes a PEM encoded x509 certificate request of the node1 node to a temporary file
+func writeCSRFile(t *testing.T, dnsName string, ipAddress net.IP) string {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: "system:node:node1", Organization: []string{"system:nodes"}},
+		DNSNames:    []string{dnsName},
+		IPAddresses: []net.IP{ipAddress},
+	}, priv)
+	require.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "node1.csr")
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes}), 0o600))
+
+	return path
+}
+
+func TestValidateExitCodes(t *testing.T) {
+	configArgs := []string{`--provider-regex=^node1\.test\.ch$`, "--provider-ip-prefixes=192.168.0.0/16", "--bypass-dns-resolution"}
+
+	invalidCSRFile := filepath.Join(t.TempDir(), "invalid.csr")
+	require.Nil(t, os.WriteFile(invalidCSRFile, []byte("not a CSR"), 0o600))
+
+	testCases := []struct {
+		name     string
+		args     []string
+		exitCode int
+	}{
+		{"valid configuration", configArgs, 0},
+		{"unknown flag", append(configArgs, "--no-such-flag"), 2},
+		{"invalid configuration", append(configArgs, "--log-format=yaml"), 2},
+		{"approved CSR", append(configArgs, "--csr-file="+writeCSRFile(t, "node1.test.ch", net.ParseIP("192.168.14.1"))), 0},
+		{"denied CSR", append(configArgs, "--csr-file="+writeCSRFile(t, "node1.test.ch", net.ParseIP("10.0.0.1"))), 1},
+		{"invalid CSR", append(configArgs, "--csr-file="+invalidCSRFile), 2},
+	}
+
+	for _, tc := range testCases {
+		var out bytes.Buffer
+
+		exitCode := cmd.Validate(tc.args, &out)
+		assert.Equal(t, tc.exitCode, exitCode, "%s: %s", tc.name, out.String())
+	}
+}
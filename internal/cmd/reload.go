@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+// reloadProviderRules parses the command line arguments, the environment variables,
+// the config file and the provider regex ConfigMap again, and swaps the provider regexes and IP sets of the
+// reconciler. the previous rules are kept when the new configuration is invalid, or fails the
+// regex self-test.
+func reloadProviderRules(csrController *controller.CertificateSigningRequestReconciler, args []string, log logr.Logger) error {
+	config, err := ParseConfig(args)
+	if err != nil {
+		return err
+	}
+
+	if err = config.Validate(); err != nil {
+		return err
+	}
+
+	if _, err = applyRegexConfigMap(context.Background(), csrController.ClientSet, config); err != nil {
+		return err
+	}
+
+	if err = regexSelfTest(config, func(err error) { log.Error(err, "Ignoring the failed regex self-test") }); err != nil {
+		return err
+	}
+
+	rules, _ := controller.CompileProviderRules(config)
+
+	csrController.SetProviderRules(rules)
+
+	return nil
+}
+
+// applyRegexConfigMap replaces the provider regex of the validated configuration by the one of the
+// RegexConfigMapRef, and returns the resource version of the ConfigMap. the configuration is left
+// untouched without RegexConfigMapRef, i.e. the provider-regex flag applies
+func applyRegexConfigMap(ctx context.Context, clientSet clientset.Interface,
+	config *controller.Config) (resourceVersion string, err error) {
+	if config.RegexConfigMapRef == "" {
+		return "", nil
+	}
+
+	if config.RegexStr, resourceVersion, err = controller.ReadRegexConfigMap(ctx, clientSet, config); err != nil {
+		return "", err
+	}
+
+	if _, err = controller.CompileProviderRules(config); err != nil {
+		return "", fmt.Errorf("invalid provider regex ConfigMap %s: %w", config.RegexConfigMapRef, err)
+	}
+
+	return resourceVersion, nil
+}
+
+// regexSelfTest evaluates the provider regexes of the validated configuration against the samples
+// of the RegexSelfTestFile. the disagreements are passed to warn instead of being returned when
+// RegexSelfTestWarnOnly is set
+func regexSelfTest(config *controller.Config, warn func(error)) error {
+	if config.RegexSelfTestFile == "" {
+		return nil
+	}
+
+	selfTest, err := controller.ReadRegexSelfTest(config.RegexSelfTestFile)
+	if err != nil {
+		return err
+	}
+
+	rules, _ := controller.CompileProviderRules(config)
+
+	err = selfTest.Run(&rules)
+	if err != nil && config.RegexSelfTestWarnOnly {
+		warn(err)
+		return nil
+	}
+
+	return err
+}
+
+// configWatcher is a manager Runnable calling reload whenever the config file changes
+type configWatcher struct {
+	path   string
+	reload func() error
+	log    logr.Logger
+}
+
+// NeedLeaderElection returns false, since every replica must keep its rules up-to-date
+func (w *configWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start watches the directory of the config file until ctx is done. the directory is
+// watched rather than the file, since a mounted ConfigMap is updated by atomically
+// replacing the ..data symlink
+func (w *configWatcher) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if name := filepath.Base(event.Name); name != filepath.Base(w.path) && name != "..data" {
+				continue
+			}
+
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+
+			if err := w.reload(); err != nil {
+				w.log.Error(err, "Invalid configuration, keeping the previous provider regexes and IP prefixes")
+				continue
+			}
+
+			w.log.V(0).Info("Provider regexes and IP prefixes reloaded", "file", w.path)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			w.log.Error(err, "Error while watching the config file")
+		}
+	}
+}
+
+// regexConfigMapWatcher is a manager Runnable calling reload whenever the resource version of the
+// provider regex ConfigMap changes, which is polled every interval
+type regexConfigMapWatcher struct {
+	config          *controller.Config
+	clientSet       clientset.Interface
+	interval        time.Duration
+	resourceVersion string // of the ConfigMap read at startup
+	reload          func() error
+	log             logr.Logger
+}
+
+// NeedLeaderElection returns false, since every replica must keep its rules up-to-date
+func (w *regexConfigMapWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start polls the ConfigMap until ctx is done
+func (w *regexConfigMapWatcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		_, resourceVersion, err := controller.ReadRegexConfigMap(ctx, w.clientSet, w.config)
+		if err != nil {
+			w.log.Error(err, "Unable to read the provider regex ConfigMap, keeping the previous provider regex")
+			continue
+		} else if resourceVersion == w.resourceVersion {
+			continue
+		}
+
+		// an invalid ConfigMap version is logged once, rather than at each poll
+		w.resourceVersion = resourceVersion
+
+		if err := w.reload(); err != nil {
+			w.log.Error(err, "Invalid configuration, keeping the previous provider regexes and IP prefixes")
+			continue
+		}
+
+		w.log.V(0).Info("Provider regex reloaded", "configMap", w.config.RegexConfigMapRef)
+	}
+}
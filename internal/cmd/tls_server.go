@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// DefaultTLSServerShutdownTimeout is the time given to the in-flight requests once the TLS servers are stopping
+const DefaultTLSServerShutdownTimeout = 5 * time.Second
+
+// tlsServer is a manager Runnable serving its handler over HTTPS, in place of the plaintext metrics or
+// health probe server of the manager. the certificate is reloaded whenever its files change
+type tlsServer struct {
+	addr     string
+	certFile string
+	keyFile  string
+	handler  http.Handler
+	log      logr.Logger
+}
+
+// NeedLeaderElection returns false, since every replica must serve its metrics and probes
+func (s *tlsServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the handler until ctx is done
+func (s *tlsServer) Start(ctx context.Context) error {
+	watcher, err := certwatcher.New(s.certFile, s.keyFile)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := watcher.Start(ctx); err != nil {
+			s.log.Error(err, "unable to watch the TLS certificate files")
+		}
+	}()
+
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.handler,
+		ReadHeaderTimeout: 32 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: watcher.GetCertificate,
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), DefaultTLSServerShutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.log.Error(err, "unable to shut the server down gracefully")
+		}
+	}()
+
+	s.log.Info("Starting server", "addr", s.addr)
+
+	if err := server.ListenAndServeTLS("", ""); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// metricsMux serves the controller-runtime metrics registry along with the extra handlers, like the
+// metrics server of the manager does
+func metricsMux(extraHandlers map[string]http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	}))
+
+	for path, handler := range extraHandlers {
+		mux.Handle(path, handler)
+	}
+
+	return mux
+}
+
+// probesMux serves the liveness and readiness checks, like the health probe server of the manager does
+func probesMux(healthzChecks, readyzChecks map[string]healthz.Checker) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	for path, checks := range map[string]map[string]healthz.Checker{"/healthz": healthzChecks, "/readyz": readyzChecks} {
+		handler := http.StripPrefix(path, &healthz.Handler{Checks: checks})
+		mux.Handle(path, handler)
+		// the subpaths serve the individual checks
+		mux.Handle(path+"/", handler)
+	}
+
+	return mux
+}
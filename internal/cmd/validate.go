@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+// Validate runs the validate subcommand: it parses and validates the configuration like the
+// controller does at startup and, when a CSR is given with --csr-file, prints the decision the
+// controller would take for it. the cluster is never contacted, hence the checks relying on the
+// cluster objects are skipped. the exit code is 0 when the configuration is valid and the CSR approved,
+// 1 when the CSR is denied and 2 when the configuration or the CSR is invalid.
+func Validate(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("kubelet-csr-approver validate", flag.ContinueOnError)
+	buildConfig := registerFlags(fs)
+	csrFile := fs.String("csr-file", "",
+		"PEM encoded x509 certificate request, or CertificateSigningRequest manifest, to evaluate against the configuration")
+
+	if err := parseFlags(fs, args); errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		fmt.Fprintf(out, "unable to parse args/envs: %v\n", err)
+
+		return 2
+	}
+
+	config := buildConfig()
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(out, "%v\n", err)
+
+		return 2
+	}
+
+	if err := regexSelfTest(config, func(err error) { fmt.Fprintf(out, "warning: %v\n", err) }); err != nil {
+		fmt.Fprintf(out, "%v\n", err)
+
+		return 2
+	}
+
+	fmt.Fprintln(out, "the configuration is valid")
+
+	if *csrFile == "" {
+		return 0
+	}
+
+	skipped := disableClusterChecks(config)
+	for _, option := range skipped {
+		fmt.Fprintf(out, "skipping the %s check, which requires the cluster\n", option)
+	}
+
+	csrController := &controller.CertificateSigningRequestReconciler{
+		Config: *config,
+	}
+	setupReconciler(csrController)
+
+	csr, err := readCSRFile(*csrFile)
+	if err != nil {
+		fmt.Fprintf(out, "unable to read the CSR: %v\n", err)
+
+		return 2
+	}
+
+	valid, rule, reason, err := evaluateCSR(log.IntoContext(context.Background(), logr.Discard()), csrController, csr)
+	if err != nil {
+		fmt.Fprintf(out, "unable to evaluate the CSR: %v\n", err)
+
+		return 2
+	}
+
+	if !valid {
+		fmt.Fprintf(out, "denied (rule %s): %s\n", rule, reason)
+
+		return 1
+	}
+
+	fmt.Fprintln(out, "approved")
+
+	return 0
+}
+
+// disableClusterChecks turns off the configuration options which retrieve objects from the
+// cluster, and returns the names of the corresponding flags
+func disableClusterChecks(config *controller.Config) (skipped []string) {
+	for _, option := range []struct {
+		flag    string
+		enabled bool
+		disable func()
+	}{
+		{"verify-node-ip-addresses", config.VerifyNodeIPAddresses, func() { config.VerifyNodeIPAddresses = false }},
+		{"verify-node-dns-names", config.VerifyNodeDNSNames, func() { config.VerifyNodeDNSNames = false }},
+		{"verify-cloud-instance-id", config.VerifyCloudInstanceID, func() { config.VerifyCloudInstanceID = false }},
+		{"allow-pod-cidr-ips", config.AllowPodCIDRIPs, func() { config.AllowPodCIDRIPs = false }},
+		{"require-node-ready", config.RequireNodeReady, func() { config.RequireNodeReady = false }},
+		{"verify-requestor-access", config.VerifyRequestorAccess, func() { config.VerifyRequestorAccess = false }},
+		{"relaxed-renewal-mode", config.RelaxedRenewalMode, func() { config.RelaxedRenewalMode = false }},
+		{"dns-name-node-annotation", config.DNSNameNodeAnnotation != "", func() { config.DNSNameNodeAnnotation = "" }},
+		{"node-address-annotation", config.NodeAddressAnnotation != "", func() { config.NodeAddressAnnotation = "" }},
+		{"overrides-configmap", config.OverridesConfigMap != "", func() { config.OverridesConfigMap = "" }},
+		{"provider-regex-configmap", config.RegexConfigMapRef != "", func() {
+			config.RegexConfigMapRef, config.RegexConfigMapInterval = "", 0
+		}},
+		{"client requestor authorization", config.EnableClientCSRApproval && !config.SkipClientRequestorReview,
+			func() { config.SkipClientRequestorReview = true }},
+	} {
+		if option.enabled {
+			option.disable()
+			skipped = append(skipped, option.flag)
+		}
+	}
+
+	return skipped
+}
+
+// readCSRFile reads either a PEM encoded x509 certificate request, which is wrapped into a
+// kubelet-serving CSR requested by the node of its CommonName, or a CertificateSigningRequest
+// manifest (e.g. the output of kubectl get csr -o yaml)
+func readCSRFile(path string) (*certificatesv1.CertificateSigningRequest, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block == nil {
+		var csr certificatesv1.CertificateSigningRequest
+		if err := yaml.Unmarshal(data, &csr); err != nil {
+			return nil, fmt.Errorf("the file is neither a PEM certificate request nor a CSR manifest: %w", err)
+		}
+
+		if csr.Kind != "CertificateSigningRequest" {
+			return nil, fmt.Errorf("the file is neither a PEM certificate request nor a CSR manifest")
+		}
+
+		return &csr, nil
+	}
+
+	x509cr, err := controller.ParseCSR(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: filepath.Base(path)},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    data,
+			SignerName: certificatesv1.KubeletServingSignerName,
+			Username:   x509cr.Subject.CommonName,
+			Groups:     []string{"system:nodes", "system:authenticated"},
+			Usages:     controller.DefaultServingUsages,
+		},
+	}, nil
+}
+
+// evaluateCSR runs the checks of the CSR signer, like the reconciler does
+func evaluateCSR(ctx context.Context, csrController *controller.CertificateSigningRequestReconciler,
+	csr *certificatesv1.CertificateSigningRequest) (valid bool, rule, reason string, err error) {
+	x509cr, err := controller.ParseCSR(csr.Spec.Request)
+	if err != nil {
+		return false, "", "", fmt.Errorf("the CSR spec.request could not be parsed as a x509 Cert Request: %w", err)
+	}
+
+	if err = x509cr.CheckSignature(); err != nil {
+		return false, "", "", fmt.Errorf("the signature of the x509 Cert Request doesn't verify against its public key: %w", err)
+	}
+
+	if csr.Spec.SignerName == certificatesv1.KubeAPIServerClientKubeletSignerName {
+		return csrController.ClientCSRChecks(ctx, csr, x509cr)
+	}
+
+	return csrController.ServingCSRChecks(ctx, csr, x509cr)
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+//+kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests/status,verbs=update
+
+// approvalConditionType returns the condition type set on the approved CSRs, Approved unless
+// overridden through the ApprovalConditionType
+func (r *CertificateSigningRequestReconciler) approvalConditionType() certificatesv1.RequestConditionType {
+	if r.ApprovalConditionType == "" {
+		return certificatesv1.CertificateApproved
+	}
+
+	return certificatesv1.RequestConditionType(r.ApprovalConditionType)
+}
+
+// customApprovalCondition returns true when the approved CSRs get a condition other than Approved,
+// which is set through the status subresource: the CSRs are then only signed once another tool
+// (e.g. a gitops workflow) set their Approved condition
+func (r *CertificateSigningRequestReconciler) customApprovalCondition() bool {
+	return r.approvalConditionType() != certificatesv1.CertificateApproved
+}
+
+// approvalCondition returns whether the CSR was approved, i.e. has an Approved or an
+// ApprovalConditionType condition, or denied
+func (r *CertificateSigningRequestReconciler) approvalCondition(csr *certificatesv1.CertificateSigningRequest) (approved, denied bool) {
+	approved, denied = GetCertApprovalCondition(&csr.Status)
+
+	if r.customApprovalCondition() {
+		for _, c := range csr.Status.Conditions {
+			if c.Type == r.approvalConditionType() {
+				approved = true
+			}
+		}
+	}
+
+	return approved, denied
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ApprovalWindow is a daily time range, restricted to some weekdays.
+// a window whose End is before its Start spans midnight.
+type ApprovalWindow struct {
+	Weekdays [7]bool // indexed by time.Weekday
+	Start    time.Duration
+	End      time.Duration
+}
+
+//nolint:gochecknoglobals // lookup table of the weekday abbreviations
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseApprovalWindows parses a semicolon separated list of approval windows of the
+// form `[days ]HH:MM-HH:MM`, where days is a comma separated list of weekdays or
+// weekday ranges, e.g. `Mon-Fri 08:00-17:00;Sat,Sun 10:00-12:00`.
+// a window without days applies to every day of the week.
+func ParseApprovalWindows(windowsStr string) ([]ApprovalWindow, error) {
+	var windows []ApprovalWindow
+
+	for _, windowStr := range strings.Split(windowsStr, ";") {
+		fields := strings.Fields(windowStr)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var window ApprovalWindow
+
+		switch len(fields) {
+		case 1:
+			for d := range window.Weekdays {
+				window.Weekdays[d] = true
+			}
+		case 2:
+			if err := parseWeekdays(fields[0], &window.Weekdays); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid approval window %q, expected [days ]HH:MM-HH:MM", windowStr)
+		}
+
+		timeRange := strings.Split(fields[len(fields)-1], "-")
+		if len(timeRange) != 2 {
+			return nil, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", fields[len(fields)-1])
+		}
+
+		var err error
+		if window.Start, err = parseTimeOfDay(timeRange[0]); err != nil {
+			return nil, err
+		}
+
+		if window.End, err = parseTimeOfDay(timeRange[1]); err != nil {
+			return nil, err
+		}
+
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+func parseWeekdays(daysStr string, days *[7]bool) error {
+	for _, dayRange := range strings.Split(daysStr, ",") {
+		bounds := strings.Split(strings.ToLower(dayRange), "-")
+
+		first, ok := weekdays[bounds[0]]
+		if !ok || len(bounds) > 2 {
+			return fmt.Errorf("invalid weekday %q", dayRange)
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return fmt.Errorf("invalid weekday %q", dayRange)
+			}
+		}
+
+		// ranges can wrap around the end of the week, e.g. Sat-Mon
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseTimeOfDay(timeStr string) (time.Duration, error) {
+	t, err := time.Parse("15:04", timeStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", timeStr)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains returns true when t falls into the approval window
+func (w ApprovalWindow) Contains(t time.Time) bool {
+	sinceMidnight := t.Sub(midnight(t))
+
+	if w.Start <= w.End {
+		return w.Weekdays[t.Weekday()] && sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+
+	// the window spans midnight: it either started today, or the day before
+	return (w.Weekdays[t.Weekday()] && sinceMidnight >= w.Start) ||
+		(w.Weekdays[(t.Weekday()+6)%7] && sinceMidnight < w.End)
+}
+
+// InApprovalWindows returns true when no approval window is configured, or when t falls
+// into one of the windows
+func InApprovalWindows(windows []ApprovalWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextApprovalWindow returns the time the next approval window opens after t
+func NextApprovalWindow(windows []ApprovalWindow, t time.Time) time.Time {
+	var next time.Time
+
+	for _, w := range windows {
+		for d := 0; d <= 7; d++ {
+			day := midnight(t).AddDate(0, 0, d)
+			start := day.Add(w.Start)
+
+			if w.Weekdays[day.Weekday()] && start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+
+	return next
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
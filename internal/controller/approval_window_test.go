@@ -0,0 +1,42 @@
+package controller_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestApprovalWindows(t *testing.T) {
+	windows, err := controller.ParseApprovalWindows("Mon-Fri 08:00-17:00; Sat 22:00-02:00")
+	require.Nil(t, err)
+	require.Len(t, windows, 2)
+
+	// 2022-06-06 is a Monday
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2022, 6, 6, hour, minute, 0, 0, time.UTC)
+	}
+
+	assert.True(t, controller.InApprovalWindows(windows, monday(8, 0)))
+	assert.True(t, controller.InApprovalWindows(windows, monday(16, 59)))
+	assert.False(t, controller.InApprovalWindows(windows, monday(17, 0)))
+	assert.False(t, controller.InApprovalWindows(windows, monday(7, 30)))
+	assert.Equal(t, monday(8, 0), controller.NextApprovalWindow(windows, monday(7, 30)))
+
+	// the Saturday window spans midnight, into Sunday
+	assert.True(t, controller.InApprovalWindows(windows, monday(23, 0).AddDate(0, 0, 5)))
+	assert.True(t, controller.InApprovalWindows(windows, monday(1, 0).AddDate(0, 0, 6)))
+	assert.False(t, controller.InApprovalWindows(windows, monday(3, 0).AddDate(0, 0, 6)))
+	assert.Equal(t, monday(22, 0).AddDate(0, 0, 5), controller.NextApprovalWindow(windows, monday(18, 0).AddDate(0, 0, 4)))
+	assert.Equal(t, monday(8, 0).AddDate(0, 0, 7), controller.NextApprovalWindow(windows, monday(3, 0).AddDate(0, 0, 6)))
+
+	assert.True(t, controller.InApprovalWindows(nil, monday(3, 0)))
+
+	for _, invalid := range []string{"Mon-Fri", "Mon 8-17", "Mon-Fro 08:00-17:00", "Mon 08:00-17:00 extra", "25:00-26:00"} {
+		_, err := controller.ParseApprovalWindows(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
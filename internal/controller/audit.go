@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// AuditEntry is the record of a CSR decision, written as one JSON line to the audit log
+type AuditEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	CSRName     string    `json:"csr_name"`
+	Node        string    `json:"node"`
+	Requestor   string    `json:"requestor"`
+	Decision    string    `json:"decision"`
+	Reason      string    `json:"reason"`
+	DNSNames    []string  `json:"dns_names"`
+	IPAddresses []string  `json:"ip_addresses"`
+}
+
+// AuditLog appends the CSR decisions to a file, in the JSON lines format.
+// the file is reopened on SIGHUP, so that it can be rotated
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	log  logr.Logger
+}
+
+// NewAuditLog opens (or creates) the audit log file at path, in append-only mode
+func NewAuditLog(path string, log logr.Logger) (*AuditLog, error) {
+	a := &AuditLog{path: path, log: log}
+	if err := a.Reopen(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Reopen closes the audit log file and opens it again, e.g. once it has been rotated
+func (a *AuditLog) Reopen() error {
+	file, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file != nil {
+		a.file.Close()
+	}
+
+	a.file = file
+
+	return nil
+}
+
+// Write appends the entry to the audit log file
+func (a *AuditLog) Write(entry *AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, err = a.file.Write(append(line, '\n'))
+
+	return err
+}
+
+// NeedLeaderElection returns false, since every replica must be able to reopen its audit log
+func (a *AuditLog) NeedLeaderElection() bool {
+	return false
+}
+
+// Start reopens the audit log file on every SIGHUP until ctx is done, and then closes it
+func (a *AuditLog) Start(ctx context.Context) error {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			return a.file.Close()
+		case <-sighup:
+			if err := a.Reopen(); err != nil {
+				a.log.Error(err, "unable to reopen the audit log file, still writing to the previous one", "path", a.path)
+				continue
+			}
+
+			a.log.V(1).Info("audit log file reopened", "path", a.path)
+		}
+	}
+}
+
+// auditDecision writes the decision taken on the CSR to the audit log, when one is configured
+func (r *CertificateSigningRequestReconciler) auditDecision(l logr.Logger, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest, valid bool, reason string) {
+	if r.AuditLog == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Timestamp: time.Now().UTC(),
+		CSRName:   csr.Name,
+		Node:      nodeNameOf(csr),
+		Requestor: csr.Spec.Username,
+		Decision:  "denied",
+		Reason:    reason,
+	}
+
+	if valid {
+		entry.Decision = "approved"
+	}
+
+	if x509cr != nil {
+		entry.DNSNames = x509cr.DNSNames
+
+		for _, ip := range x509cr.IPAddresses {
+			entry.IPAddresses = append(entry.IPAddresses, ip.String())
+		}
+	}
+
+	if err := r.AuditLog.Write(&entry); err != nil {
+		l.Error(err, "unable to write the decision to the audit log")
+	}
+}
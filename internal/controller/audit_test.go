@@ -0,0 +1,41 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestAuditLogReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	auditLog, err := controller.NewAuditLog(path, logr.Discard())
+	require.Nil(t, err)
+
+	require.Nil(t, auditLog.Write(&controller.AuditEntry{CSRName: "first", Decision: "approved"}))
+
+	// the file is rotated, the following entries must go to a new file
+	require.Nil(t, os.Rename(path, path+".1"))
+	require.Nil(t, auditLog.Reopen())
+	require.Nil(t, auditLog.Write(&controller.AuditEntry{CSRName: "second", Decision: "denied", Reason: "dns"}))
+
+	for file, csrName := range map[string]string{path + ".1": "first", path: "second"} {
+		content, err := os.ReadFile(file)
+		require.Nil(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		require.Len(t, lines, 1)
+
+		var entry controller.AuditEntry
+		require.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+		assert.Equal(t, csrName, entry.CSRName)
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// CompileCELPolicy compiles the CEL expression of Config.CELPolicy, which must evaluate to a bool.
+// the expression is given the csr variable, a map with the following keys:
+//   - name, username, signerName and node (strings)
+//   - groups, dnsNames and ipAddresses (lists of strings)
+//   - expirationSeconds (int, 0 when the CSR doesn't request an expiration)
+func CompileCELPolicy(expression string) (cel.Program, error) {
+	env, err := cel.NewEnv(cel.Variable("csr", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	if !cel.BoolType.IsAssignableType(ast.OutputType()) {
+		return nil, fmt.Errorf("the CEL policy evaluates to %s instead of bool", ast.OutputType())
+	}
+
+	return env.Program(ast)
+}
+
+// CELPolicyCheck denies the CSRs for which the CEL policy doesn't evaluate to true.
+// an expression which can't be evaluated against the CSR, e.g. because of a missing key, denies it too
+func (r *CertificateSigningRequestReconciler) CELPolicyCheck(csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (valid bool, reason string) {
+	if r.CELPolicyProgram == nil {
+		return true, ""
+	}
+
+	var expirationSeconds int64
+	if csr.Spec.ExpirationSeconds != nil {
+		expirationSeconds = int64(*csr.Spec.ExpirationSeconds)
+	}
+
+	ipAddresses := make([]string, 0, len(x509cr.IPAddresses))
+	for _, ip := range x509cr.IPAddresses {
+		ipAddresses = append(ipAddresses, ip.String())
+	}
+
+	out, _, err := r.CELPolicyProgram.Eval(map[string]interface{}{
+		"csr": map[string]interface{}{
+			"name":              csr.Name,
+			"username":          csr.Spec.Username,
+			"signerName":        csr.Spec.SignerName,
+			"node":              nodeNameOf(csr),
+			"groups":            append([]string{}, csr.Spec.Groups...),
+			"dnsNames":          append([]string{}, x509cr.DNSNames...),
+			"ipAddresses":       ipAddresses,
+			"expirationSeconds": expirationSeconds,
+		},
+	})
+	if err != nil {
+		return false, "The CEL policy could not be evaluated against the CSR: " + err.Error()
+	}
+
+	if allowed, ok := out.Value().(bool); !ok || !allowed {
+		return false, "The CSR does not satisfy the CEL policy"
+	}
+
+	return true, ""
+}
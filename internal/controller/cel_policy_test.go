@@ -0,0 +1,55 @@
+package controller_test
+
+import (
+	"crypto/x509"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	certificatesv1 "k8s.io/api/certificates/v1"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestCompileCELPolicy(t *testing.T) {
+	_, err := controller.CompileCELPolicy("csr.dnsNames.size() == csr.ipAddresses.size()")
+	assert.Nil(t, err)
+
+	for _, invalid := range []string{"csr.dnsNames.size() ==", "csr.dnsNames.size()", "unknown == 1"} {
+		_, err := controller.CompileCELPolicy(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestCELPolicyCheck(t *testing.T) {
+	// the CSRs of the worker nodes can't request certificates valid for more than 90 days
+	program, err := controller.CompileCELPolicy(`!csr.node.startsWith("worker-") || csr.expirationSeconds <= 90 * 24 * 3600`)
+	require.Nil(t, err)
+
+	r := controller.CertificateSigningRequestReconciler{Config: controller.Config{CELPolicyProgram: program}}
+	x509cr := &x509.CertificateRequest{DNSNames: []string{"worker-1.example.com"}, IPAddresses: []net.IP{net.ParseIP("192.168.14.34")}}
+
+	expirationSeconds := int32(365 * 24 * 3600)
+	csr := &certificatesv1.CertificateSigningRequest{Spec: certificatesv1.CertificateSigningRequestSpec{
+		Username:          "system:node:worker-1",
+		ExpirationSeconds: &expirationSeconds,
+	}}
+
+	valid, reason := r.CELPolicyCheck(csr, x509cr)
+	assert.False(t, valid)
+	assert.Contains(t, reason, "CEL policy")
+
+	csr.Spec.Username = "system:node:control-plane-1"
+	valid, _ = r.CELPolicyCheck(csr, x509cr)
+	assert.True(t, valid)
+
+	// a missing key doesn't approve the CSR
+	program, err = controller.CompileCELPolicy("csr.unknown == 1")
+	require.Nil(t, err)
+
+	r.CELPolicyProgram = program
+	valid, reason = r.CELPolicyCheck(csr, x509cr)
+	assert.False(t, valid)
+	assert.Contains(t, reason, "could not be evaluated")
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// ClientCSRChecks is the rule set applied to kube-apiserver-client-kubelet CSRs,
+// i.e. the CSRs created by the kubelets during TLS bootstrap. It verifies that:
+// the CSR has not been Pending for longer than the maximum pending age, if set
+// the x509 CR subject CommonName is system:node:<nodename>
+// (opt-in) the node name is made of lowercase alphanumerical characters, '-' and '.'
+// (opt-in) the CSR requestor, when it is a node, is the node of the x509 CR subject CommonName
+// the CSR requestor is authorized to request a kubelet client certificate, see ClientRequestorCheck
+// the x509 CR subject Organization is exactly system:nodes, or complies with the allowed organizations
+// the x509 CR does not contain any SAN
+// the CSR doesn't request any key usage outside of the ClientUsages
+// the x509 CR public key complies with the allowed algorithms and key size
+// the CSR spec.expirationSeconds, if specified, is not longer than the maximum allowed
+func (r *CertificateSigningRequestReconciler) ClientCSRChecks(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (valid bool, rule, reason string, err error) {
+	if valid, reason = r.PendingAgeCheck(csr); !valid {
+		return false, ruleStale, reason, nil
+	}
+
+	nodeName := strings.TrimPrefix(x509cr.Subject.CommonName, "system:node:")
+	if nodeName == x509cr.Subject.CommonName || nodeName == "" {
+		return false, ruleCommonName, "The x509 Cert Request CommonName is not of the form system:node:<nodename>", nil
+	}
+
+	if _, ok := NodeNameFromCommonName(x509cr.Subject.CommonName); r.ValidateCommonNameFormat && !ok {
+		return false, ruleCommonName, "The x509 Cert Request CommonName " + x509cr.Subject.CommonName + " is not of the form " +
+			"system:node:<nodename>, with a node name made of lowercase alphanumerical characters, '-' and '.'", nil
+	}
+
+	// a node renewing its client certificate can only request a certificate for itself,
+	// while the requestor identity can't be verified for the bootstrap tokens
+	if r.VerifyRequestorIdentity && strings.HasPrefix(csr.Spec.Username, "system:node:") && csr.Spec.Username != x509cr.Subject.CommonName {
+		return false, ruleRequestor, "The CSR requestor " + csr.Spec.Username + " doesn't match the x509 Cert Request CommonName " +
+			x509cr.Subject.CommonName + ", a node can only request a certificate for itself", nil
+	}
+
+	if valid, reason, err = r.ClientRequestorCheck(ctx, csr, x509cr); !valid {
+		return false, ruleRequestor, reason, err
+	}
+
+	if valid, reason = r.OrganizationCheck(x509cr); !valid {
+		return false, ruleOrganization, reason, nil
+	}
+
+	if len(x509cr.DNSNames)+len(x509cr.IPAddresses)+len(x509cr.EmailAddresses)+len(x509cr.URIs) > 0 {
+		return false, ruleSAN, "The x509 Cert Request of a kubelet client certificate must not contain any SAN", nil
+	}
+
+	if valid, reason = r.ClientUsageCheck(csr); !valid {
+		return false, ruleUsage, reason, nil
+	}
+
+	if valid, reason = r.KeyCheck(x509cr); !valid {
+		return false, ruleKey, reason, nil
+	}
+
+	if valid, reason = r.ExpirationCheck(csr); !valid {
+		return false, ruleExpiration, reason, nil
+	}
+
+	return true, "", "", nil
+}
+
+// hasSystemNodesOrg returns true when the x509 CR subject Organization is exactly system:nodes
+func hasSystemNodesOrg(x509cr *x509.CertificateRequest) bool {
+	return len(x509cr.Subject.Organization) == 1 && x509cr.Subject.Organization[0] == "system:nodes"
+}
+
+// organization match modes, i.e. how the x509 CR subject Organization is compared with the AllowedOrganizations
+const (
+	OrganizationMatchSubset   = "subset"   // every organization of the subject must be allowed
+	OrganizationMatchSuperset = "superset" // the subject must include every allowed organization, and may have others
+)
+
+// OrganizationCheck verifies the x509 CR subject Organization: it must be exactly system:nodes, unless
+// AllowedOrganizations are configured. system:nodes must then be one of the organizations, which are
+// compared with the allowed ones according to the OrganizationMatchMode
+func (r *CertificateSigningRequestReconciler) OrganizationCheck(x509cr *x509.CertificateRequest) (valid bool, reason string) {
+	if len(r.AllowedOrganizations) == 0 {
+		if !hasSystemNodesOrg(x509cr) {
+			return false, "The x509 Cert Request Organization must be exactly system:nodes"
+		}
+
+		return true, ""
+	}
+
+	organizations := x509cr.Subject.Organization
+	if !containsString(organizations, "system:nodes") {
+		return false, "The x509 Cert Request Organization must include system:nodes"
+	}
+
+	if r.OrganizationMatchMode == OrganizationMatchSuperset {
+		for _, allowed := range r.AllowedOrganizations {
+			if !containsString(organizations, allowed) {
+				return false, fmt.Sprintf("The x509 Cert Request Organization doesn't include %s", allowed)
+			}
+		}
+
+		return true, ""
+	}
+
+	for _, organization := range organizations {
+		if organization != "system:nodes" && !containsString(r.AllowedOrganizations, organization) {
+			return false, fmt.Sprintf("The x509 Cert Request Organization %s is not allowed", organization)
+		}
+	}
+
+	return true, ""
+}
+
+// servingOrganizationCheck runs the OrganizationCheck against the kubelet-serving CSRs, when
+// RequireSystemNodesOrg is set or AllowedOrganizations are configured
+func (r *CertificateSigningRequestReconciler) servingOrganizationCheck(x509cr *x509.CertificateRequest) (valid bool, reason string) {
+	if !r.RequireSystemNodesOrg && len(r.AllowedOrganizations) == 0 {
+		return true, ""
+	}
+
+	return r.OrganizationCheck(x509cr)
+}
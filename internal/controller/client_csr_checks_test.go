@@ -0,0 +1,87 @@
+package controller_test
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	certificatesv1 "k8s.io/api/certificates/v1"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestOrganizationCheck(t *testing.T) {
+	for _, tc := range []struct {
+		allowed       []string
+		mode          string
+		organizations []string
+		valid         bool
+	}{
+		{nil, "", []string{"system:nodes"}, true},
+		{nil, "", []string{"system:nodes", "gpu-nodes"}, false},
+		{[]string{"gpu-nodes"}, controller.OrganizationMatchSubset, []string{"system:nodes", "gpu-nodes"}, true},
+		{[]string{"gpu-nodes"}, controller.OrganizationMatchSubset, []string{"system:nodes"}, true},
+		{[]string{"gpu-nodes"}, controller.OrganizationMatchSubset, []string{"gpu-nodes"}, false},
+		{[]string{"gpu-nodes"}, controller.OrganizationMatchSubset, []string{"system:nodes", "system:masters"}, false},
+		{[]string{"gpu-nodes"}, controller.OrganizationMatchSuperset, []string{"system:nodes", "gpu-nodes", "extra"}, true},
+		{[]string{"gpu-nodes"}, controller.OrganizationMatchSuperset, []string{"system:nodes", "extra"}, false},
+	} {
+		r := controller.CertificateSigningRequestReconciler{Config: controller.Config{
+			AllowedOrganizations:  tc.allowed,
+			OrganizationMatchMode: tc.mode,
+		}}
+
+		valid, reason := r.OrganizationCheck(&x509.CertificateRequest{Subject: pkix.Name{Organization: tc.organizations}})
+		assert.Equal(t, tc.valid, valid, "%v %s %v: %s", tc.allowed, tc.mode, tc.organizations, reason)
+	}
+}
+
+func TestClientCSRChecksRequestorAndUsages(t *testing.T) {
+	clientUsages := []certificatesv1.KeyUsage{
+		certificatesv1.UsageDigitalSignature,
+		certificatesv1.UsageKeyEncipherment,
+		certificatesv1.UsageClientAuth,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		groups []string
+		usages []certificatesv1.KeyUsage
+		valid  bool
+	}{
+		{"bootstrap token", []string{"system:bootstrappers", "system:authenticated"}, clientUsages, true},
+		{"node renewal", []string{"system:nodes", "system:authenticated"}, clientUsages, true},
+		{"neither bootstrapper nor node", []string{"system:authenticated"}, clientUsages, false},
+		{"server auth usage", []string{"system:nodes"}, append(clientUsages, certificatesv1.UsageServerAuth), false},
+	} {
+		r := controller.CertificateSigningRequestReconciler{Config: controller.Config{
+			EnableClientCSRApproval:   true,
+			SkipClientRequestorReview: true,
+		}}
+
+		csr := createCsr(t, CsrParams{nodeName: "client-node"})
+		csr.Spec.SignerName = certificatesv1.KubeAPIServerClientKubeletSignerName
+		csr.Spec.Groups, csr.Spec.Usages = tc.groups, tc.usages
+		x509cr, err := controller.ParseCSR(csr.Spec.Request)
+		require.Nil(t, err, tc.name)
+
+		valid, _, reason, err := r.ClientCSRChecks(context.Background(), &csr, x509cr)
+		require.Nil(t, err, tc.name)
+		assert.Equal(t, tc.valid, valid, "%s: %s", tc.name, reason)
+	}
+
+	// the requestor access can't be reviewed without ClientSet, the CSR is processed again rather than approved
+	r := controller.CertificateSigningRequestReconciler{Config: controller.Config{EnableClientCSRApproval: true}}
+	csr := createCsr(t, CsrParams{nodeName: "client-node"})
+	csr.Spec.SignerName = certificatesv1.KubeAPIServerClientKubeletSignerName
+	csr.Spec.Groups, csr.Spec.Usages = []string{"system:nodes"}, clientUsages
+	x509cr, err := controller.ParseCSR(csr.Spec.Request)
+	require.Nil(t, err)
+
+	valid, _, _, err := r.ClientCSRChecks(context.Background(), &csr, x509cr)
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"text/template"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ConfigError lists all the problems found in an invalid configuration
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate verifies all the invariants of the configuration at once, and returns a
+// *ConfigError listing every problem found, or nil when the configuration is valid
+//
+//nolint:gocyclo // flat list of independent checks
+func (c *Config) Validate() error {
+	var problems []string
+
+	report := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if c.LogFormat != "" && c.LogFormat != "console" && c.LogFormat != "json" {
+		report("the log format should be either console or json, got %q", c.LogFormat)
+	}
+
+	if c.LogLevel < -5 || c.LogLevel > 10 {
+		report("the log level should be between -5 and 10 (included), got %d", c.LogLevel)
+	}
+
+	if c.MaxExpirationSeconds < 0 || c.MaxExpirationSeconds > 367*24*3600 {
+		report("the maximum expiration seconds cannot be lower than 0 nor greater than 367 days")
+	}
+
+	if c.MinExpirationSeconds < 0 || c.MinExpirationSeconds > c.MaxExpirationSeconds {
+		report("the minimum expiration seconds cannot be lower than 0 nor greater than the maximum expiration seconds")
+	}
+
+	if c.AllowedDNSNames < 1 || c.AllowedDNSNames > 1000 {
+		report("the number of allowed DNS names must be at least 1 and no more than 1000, got %d", c.AllowedDNSNames)
+	}
+
+	if c.AllowedIPAddresses < 0 || c.AllowedIPAddresses > 1000 {
+		report("the number of allowed IP addresses cannot be lower than 0 nor greater than 1000, got %d", c.AllowedIPAddresses)
+	}
+
+	if c.MaxApprovalsPerMinute < 0 {
+		report("the maximum number of approvals per minute cannot be lower than 0")
+	}
+
+	if c.PendingCSRsRefreshInterval < 0 {
+		report("the pending CSRs refresh interval cannot be negative")
+	}
+
+	if c.DecisionWebhookURL != "" {
+		if u, err := url.Parse(c.DecisionWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			report("the decision webhook URL %q is not an http(s) URL", c.DecisionWebhookURL)
+		}
+	}
+
+	if c.DecisionWebhookTimeout < 0 {
+		report("the decision webhook timeout cannot be negative")
+	}
+
+	switch c.WebhookFailurePolicy {
+	case "", WebhookFailurePolicyFail, WebhookFailurePolicyIgnore:
+	default:
+		report("unknown webhook failure policy %q, expected %s or %s",
+			c.WebhookFailurePolicy, WebhookFailurePolicyFail, WebhookFailurePolicyIgnore)
+	}
+
+	if c.CELPolicy != "" {
+		if _, err := CompileCELPolicy(c.CELPolicy); err != nil {
+			report("invalid CEL policy: %v", err)
+		}
+	}
+
+	if c.ShutdownGracePeriod < 0 {
+		report("the shutdown grace period cannot be negative")
+	}
+
+	if c.OverridesConfigMap != "" {
+		if namespacedName := strings.SplitN(c.OverridesConfigMap, "/", 2); len(namespacedName) != 2 ||
+			namespacedName[0] == "" || namespacedName[1] == "" {
+			report("the overrides ConfigMap %q is not of the form <namespace>/<name>", c.OverridesConfigMap)
+		}
+	}
+
+	if c.RegexConfigMapRef != "" {
+		if _, _, _, ok := parseConfigMapKeyRef(c.RegexConfigMapRef); !ok {
+			report("the provider regex ConfigMap %q is not of the form <namespace>/<name>/<key>", c.RegexConfigMapRef)
+		}
+	}
+
+	if c.RegexConfigMapInterval < 0 {
+		report("the provider regex ConfigMap interval cannot be negative")
+	} else if c.RegexConfigMapInterval > 0 && c.RegexConfigMapRef == "" {
+		report("the provider regex ConfigMap interval requires the provider regex ConfigMap to be set")
+	}
+
+	if c.ClientQPS < 0 || c.ClientBurst < 0 {
+		report("the client QPS and burst cannot be negative")
+	}
+
+	if (c.MetricsTLSCertFile == "") != (c.MetricsTLSKeyFile == "") {
+		report("the metrics TLS certificate and key files must be specified together")
+	}
+
+	if (c.ProbeTLSCertFile == "") != (c.ProbeTLSKeyFile == "") {
+		report("the health probe TLS certificate and key files must be specified together")
+	}
+
+	if c.MaxPendingAge < 0 {
+		report("the maximum pending age cannot be negative")
+	}
+
+	if c.GCApprovedAfter < 0 {
+		report("the approved CSRs garbage collection delay cannot be negative")
+	}
+
+	if c.StartupWarmupDelay < 0 {
+		report("the startup warm-up delay cannot be negative")
+	}
+
+	switch c.OrganizationMatchMode {
+	case "", OrganizationMatchSubset, OrganizationMatchSuperset:
+	default:
+		report("unknown organization match mode %q, expected %s or %s",
+			c.OrganizationMatchMode, OrganizationMatchSubset, OrganizationMatchSuperset)
+	}
+
+	for _, suffix := range c.AllowedDNSSuffixes {
+		if strings.Trim(suffix, ".") == "" {
+			report("the allowed DNS suffixes cannot be empty")
+			break
+		}
+	}
+
+	for _, organization := range c.AllowedOrganizations {
+		if organization == "" {
+			report("the allowed organizations cannot be empty")
+			break
+		}
+	}
+
+	if c.MaxConcurrentReconciles < 0 {
+		report("the maximum number of concurrent reconciles cannot be lower than 0")
+	}
+
+	if c.MaxTotalSANs < 0 {
+		report("the maximum number of SAN entries cannot be lower than 0")
+	}
+
+	if c.RequeueJitter < 0 || c.RequeueJitter >= 1 {
+		report("the requeue jitter must be within [0, 1)")
+	}
+
+	if c.MaxRetries < 0 {
+		report("the maximum number of retries cannot be lower than 0")
+	}
+
+	if _, err := CompileProviderRules(c); err != nil {
+		report("%v", err)
+	}
+
+	if c.UseReverseDNS && c.BypassDNSResolution {
+		report("the reverse DNS verification and the DNS resolution bypass are mutually exclusive")
+	}
+
+	if c.RequireResolvedIPInPrefix && c.BypassDNSResolution {
+		report("the resolved IP addresses verification and the DNS resolution bypass are mutually exclusive")
+	}
+
+	if c.RequireAllDNSResolve && c.BypassDNSResolution {
+		report("the resolution of every SAN DNS name and the DNS resolution bypass are mutually exclusive")
+	}
+
+	for _, signerName := range c.SignerNames {
+		if signerName == "" || signerName == certificatesv1.KubeAPIServerClientKubeletSignerName {
+			report("invalid kubelet serving signer name %q", signerName)
+		}
+	}
+
+	switch certificatesv1.RequestConditionType(c.ApprovalConditionType) {
+	case "", certificatesv1.CertificateApproved:
+	case certificatesv1.CertificateDenied, certificatesv1.CertificateFailed:
+		report("the %s condition type can't be set on the approved CSRs", c.ApprovalConditionType)
+	default:
+		if errs := validation.IsQualifiedName(c.ApprovalConditionType); len(errs) > 0 {
+			report("invalid approval condition type %q: %s", c.ApprovalConditionType, strings.Join(errs, ", "))
+		}
+	}
+
+	if c.NodeAddressPrefixesStr != "" && c.NodeAddressAnnotation == "" {
+		report("the node address prefixes require the node address annotation")
+	}
+
+	if c.LoadBalancerIPAnnotation != "" && !c.VerifyNodeIPAddresses {
+		report("the load balancer IP annotation requires the verification of the Node IP addresses")
+	}
+
+	if c.DNSMismatchWarnOnly && c.BypassDNSResolution {
+		report("the DNS mismatch warnings and the DNS resolution bypass are mutually exclusive")
+	}
+
+	if c.WatchConfig && c.ConfigFile == "" {
+		report("the config file must be specified to be watched")
+	}
+
+	for _, algorithm := range c.AllowedKeyAlgorithms {
+		if !isKnownKeyAlgorithm(algorithm) {
+			report("unknown public key algorithm: %s, must be one of %v", algorithm, KnownKeyAlgorithms)
+		}
+	}
+
+	if _, err := ParseDenialMessageTemplate(c.DenialMessageTemplate); err != nil {
+		report("unable to parse the denial message template: %v", err)
+	}
+
+	if c.ApprovalWindowsStr != "" {
+		if windows, err := ParseApprovalWindows(c.ApprovalWindowsStr); err != nil {
+			report("unable to parse the approval windows: %v", err)
+		} else if len(windows) == 0 {
+			report("the approval windows %q don't contain any window", c.ApprovalWindowsStr)
+		}
+	}
+
+	if _, err := labels.Parse(c.NodeLabelSelector); err != nil {
+		report("unable to parse the node label selector %s: %v", c.NodeLabelSelector, err)
+	}
+
+	if _, err := labels.Parse(c.PriorityNodeLabelSelector); err != nil {
+		report("unable to parse the priority node label selector %s: %v", c.PriorityNodeLabelSelector, err)
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+
+	return nil
+}
+
+// ParseDenialMessageTemplate parses the text/template of the denial messages. since an
+// unknown placeholder only fails on execution, the template is rendered once with empty
+// data. it returns nil when no template is configured
+func ParseDenialMessageTemplate(tmplStr string) (*template.Template, error) {
+	if tmplStr == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("denial-message").Parse(tmplStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tmpl.Execute(io.Discard, MessageData{}); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,135 @@
+package controller_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func validConfig() controller.Config {
+	return controller.Config{
+		RegexStr:             `^[\w-]*\.test\.ch$`,
+		IPPrefixesStr:        "192.168.0.0/16,fc00::/7",
+		MaxExpirationSeconds: 367 * 24 * 3600,
+		AllowedDNSNames:      1,
+		AllowedIPAddresses:   10,
+	}
+}
+
+func TestValidConfig(t *testing.T) {
+	config := validConfig()
+	assert.Nil(t, config.Validate())
+}
+
+func TestConfigValidationReportsAllProblems(t *testing.T) {
+	config := validConfig()
+	config.MaxExpirationSeconds = 400 * 24 * 3600
+	config.RegexStr = `^[\w-*\.test\.ch$`
+	config.UseReverseDNS = true
+	config.BypassDNSResolution = true
+	config.AllowedKeyAlgorithms = []string{"DSA"}
+
+	err := config.Validate()
+	require.NotNil(t, err)
+
+	var configErr *controller.ConfigError
+	require.True(t, errors.As(err, &configErr))
+	assert.Len(t, configErr.Problems, 4)
+}
+
+func TestAllowedDNSNamesOutOfRange(t *testing.T) {
+	for _, allowedDNSNames := range []int{0, 5000} {
+		config := validConfig()
+		config.AllowedDNSNames = allowedDNSNames
+
+		var configErr *controller.ConfigError
+		require.True(t, errors.As(config.Validate(), &configErr), "allowed DNS names: %d", allowedDNSNames)
+		assert.Len(t, configErr.Problems, 1)
+	}
+}
+
+func TestLoadBalancerIPAnnotationRequiresNodeIPVerification(t *testing.T) {
+	config := validConfig()
+	config.LoadBalancerIPAnnotation = "example.com/load-balancer-ips"
+	assert.NotNil(t, config.Validate())
+
+	config.VerifyNodeIPAddresses = true
+	assert.Nil(t, config.Validate())
+}
+
+func TestLogValidation(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		level  int
+		valid  bool
+	}{
+		{"", 0, true},
+		{"console", -5, true},
+		{"json", 10, true},
+		{"yaml", 0, false},
+		{"json", 11, false},
+		{"console", -6, false},
+	} {
+		config := validConfig()
+		config.LogFormat, config.LogLevel = tc.format, tc.level
+		assert.Equal(t, tc.valid, config.Validate() == nil, "%q %d", tc.format, tc.level)
+	}
+}
+
+func TestNodeAddressPrefixesValidation(t *testing.T) {
+	config := validConfig()
+	config.NodeAddressPrefixesStr = "203.0.113.0/24"
+	assert.NotNil(t, config.Validate())
+
+	config.NodeAddressAnnotation = "example.com/nat-addresses"
+	assert.Nil(t, config.Validate())
+
+	config.NodeAddressPrefixesStr = "203.0.113.0"
+	assert.NotNil(t, config.Validate())
+}
+
+func TestApprovalConditionTypeValidation(t *testing.T) {
+	for conditionType, valid := range map[string]bool{
+		"":                      true,
+		"Approved":              true,
+		"example.com/Validated": true,
+		"Denied":                false,
+		"Failed":                false,
+		"not a condition":       false,
+	} {
+		config := validConfig()
+		config.ApprovalConditionType = conditionType
+		assert.Equal(t, valid, config.Validate() == nil, "approval condition type %q", conditionType)
+	}
+}
+
+func TestSignerNamesValidation(t *testing.T) {
+	config := validConfig()
+	config.SignerNames = []string{"kubernetes.io/kubelet-serving", "example.com/kubelet-serving"}
+	assert.Nil(t, config.Validate())
+
+	config.SignerNames = []string{"kubernetes.io/kubelet-serving", "", "kubernetes.io/kube-apiserver-client-kubelet"}
+
+	var configErr *controller.ConfigError
+	require.True(t, errors.As(config.Validate(), &configErr))
+	assert.Len(t, configErr.Problems, 2)
+}
+
+func TestRegexConfigMapValidation(t *testing.T) {
+	config := validConfig()
+	config.RegexConfigMapRef = "kube-system/csr-policy/provider-regex"
+	config.RegexConfigMapInterval = time.Minute
+	assert.Nil(t, config.Validate())
+
+	for _, ref := range []string{"kube-system/csr-policy", "kube-system//provider-regex", ""} {
+		config = validConfig()
+		config.RegexConfigMapRef = ref
+		config.RegexConfigMapInterval = time.Minute
+		assert.NotNil(t, config.Validate(), "provider regex ConfigMap %q", ref)
+	}
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CSRApprover performs the Kubernetes API operations the decisions on the CSRs rely on. the
+// reconciler defaults to the Kubernetes API, and tests may provide a fake not requiring one.
+type CSRApprover interface {
+	// Get returns the CSR of the given name
+	Get(ctx context.Context, name string) (*certificatesv1.CertificateSigningRequest, error)
+	// Approve updates the approval of the CSR, to which an Approved (or ApprovalConditionType) condition was appended
+	Approve(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) error
+	// Deny updates the approval of the CSR, to which a Denied condition was appended
+	Deny(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) error
+	// Annotate sets the given annotations on the CSR, leaving its other annotations untouched
+	Annotate(ctx context.Context, csr *certificatesv1.CertificateSigningRequest, annotations map[string]string) error
+}
+
+// errNoClientSet is returned by the checks relying on the Kubernetes API other than through the
+// CSRApprover, when the reconciler has no ClientSet (e.g. in the tests using a fake Approver)
+var errNoClientSet = errors.New("the reconciler has no Kubernetes ClientSet")
+
+// kubeCSRApprover is the CSRApprover of the Kubernetes API: the CSRs are read from the
+// (cached) controller-runtime client and their approval updated through client-go. with
+// statusApproval, the custom condition of the approved CSRs is set through the status subresource
+type kubeCSRApprover struct {
+	reader         client.Reader
+	clientSet      clientset.Interface
+	statusApproval bool
+}
+
+func (a *kubeCSRApprover) Get(ctx context.Context, name string) (*certificatesv1.CertificateSigningRequest, error) {
+	var csr certificatesv1.CertificateSigningRequest
+	if err := a.reader.Get(ctx, types.NamespacedName{Name: name}, &csr); err != nil {
+		return nil, err
+	}
+
+	return &csr, nil
+}
+
+func (a *kubeCSRApprover) Approve(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) error {
+	if a.statusApproval {
+		_, err := a.clientSet.CertificatesV1().CertificateSigningRequests().UpdateStatus(ctx, csr, metav1.UpdateOptions{})
+		return err
+	}
+
+	return a.updateApproval(ctx, csr)
+}
+
+func (a *kubeCSRApprover) Deny(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) error {
+	return a.updateApproval(ctx, csr)
+}
+
+// Annotate merge-patches the annotations: the CSR spec is immutable and its conditions are set
+// through the approval subresource
+func (a *kubeCSRApprover) Annotate(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = a.clientSet.CertificatesV1().CertificateSigningRequests().Patch(ctx, csr.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+
+	return err
+}
+
+func (a *kubeCSRApprover) updateApproval(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) error {
+	_, err := a.clientSet.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{})
+
+	return err
+}
+
+// csrApprover returns the Approver of the reconciler, or the Kubernetes API one when it isn't set
+func (r *CertificateSigningRequestReconciler) csrApprover() CSRApprover {
+	if r.Approver != nil {
+		return r.Approver
+	}
+
+	return &kubeCSRApprover{reader: r.Client, clientSet: r.ClientSet, statusApproval: r.customApprovalCondition()}
+}
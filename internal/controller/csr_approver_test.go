@@ -0,0 +1,222 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+// fakeCSRApprover serves the CSRs from memory, and records the approved, denied and annotated ones
+type fakeCSRApprover struct {
+	csrs     map[string]*certificatesv1.CertificateSigningRequest
+	approved []string
+	denied   []string
+}
+
+func (f *fakeCSRApprover) Get(ctx context.Context, name string) (*certificatesv1.CertificateSigningRequest, error) {
+	csr, ok := f.csrs[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(certificatesv1.Resource("certificatesigningrequests"), name)
+	}
+
+	return csr.DeepCopy(), nil
+}
+
+func (f *fakeCSRApprover) Approve(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) error {
+	f.approved = append(f.approved, csr.Name)
+	f.csrs[csr.Name] = csr.DeepCopy()
+
+	return nil
+}
+
+func (f *fakeCSRApprover) Deny(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) error {
+	f.denied = append(f.denied, csr.Name)
+	f.csrs[csr.Name] = csr.DeepCopy()
+
+	return nil
+}
+
+func (f *fakeCSRApprover) Annotate(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	annotations map[string]string) error {
+	stored := f.csrs[csr.Name]
+	if stored.Annotations == nil {
+		stored.Annotations = make(map[string]string, len(annotations))
+	}
+
+	for key, value := range annotations {
+		stored.Annotations[key] = value
+	}
+
+	return nil
+}
+
+func TestReconcileWithFakeApprover(t *testing.T) {
+	validCsr := createCsr(t, CsrParams{csrName: "fake-approver-valid", nodeName: "node-fake", dnsName: "node-fake.test.ch"})
+	invalidCsr := createCsr(t, CsrParams{
+		csrName:       "fake-approver-invalid",
+		nodeName:      "node-fake",
+		dnsName:       "node-fake.test.ch",
+		extraDNSNames: []string{"node-fake-alt.test.ch"},
+	})
+
+	approver := &fakeCSRApprover{csrs: map[string]*certificatesv1.CertificateSigningRequest{
+		validCsr.Name:   &validCsr,
+		invalidCsr.Name: &invalidCsr,
+	}}
+
+	r := &controller.CertificateSigningRequestReconciler{
+		Config: controller.Config{
+			RegexStr:             `^[\w-]*\.test\.ch$`,
+			IPPrefixesStr:        "192.168.0.0/16",
+			MaxExpirationSeconds: 367 * 24 * 3600,
+			AllowedDNSNames:      1,
+			BypassDNSResolution:  true,
+		},
+		Approver: approver,
+	}
+	rules, err := controller.CompileProviderRules(&r.Config)
+	require.Nil(t, err)
+	r.SetProviderRules(rules)
+
+	for _, name := range []string{validCsr.Name, invalidCsr.Name, "fake-approver-deleted"} {
+		_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		require.Nil(t, err)
+	}
+
+	assert.Equal(t, []string{validCsr.Name}, approver.approved)
+	assert.Equal(t, []string{invalidCsr.Name}, approver.denied)
+
+	approved, denied := controller.GetCertApprovalCondition(&approver.csrs[validCsr.Name].Status)
+	assert.True(t, approved)
+	assert.False(t, denied)
+
+	// the CSRs are processed once, the decided ones are ignored
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: validCsr.Name}})
+	require.Nil(t, err)
+	assert.Len(t, approver.approved, 1)
+}
+
+func TestApprovalConditionType(t *testing.T) {
+	csr := createCsr(t, CsrParams{csrName: "custom-condition", nodeName: "node-custom", dnsName: "node-custom.test.ch"})
+	approver := &fakeCSRApprover{csrs: map[string]*certificatesv1.CertificateSigningRequest{csr.Name: &csr}}
+
+	r := &controller.CertificateSigningRequestReconciler{
+		Config: controller.Config{
+			RegexStr:              `^[\w-]*\.test\.ch$`,
+			IPPrefixesStr:         "192.168.0.0/16",
+			MaxExpirationSeconds:  367 * 24 * 3600,
+			AllowedDNSNames:       1,
+			BypassDNSResolution:   true,
+			ApprovalConditionType: "example.com/Validated",
+		},
+		Approver: approver,
+	}
+	rules, err := controller.CompileProviderRules(&r.Config)
+	require.Nil(t, err)
+	r.SetProviderRules(rules)
+
+	for i := 0; i < 2; i++ {
+		// the CSR with the custom condition isn't processed again
+		_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: csr.Name}})
+		require.Nil(t, err)
+	}
+
+	assert.Equal(t, []string{csr.Name}, approver.approved)
+
+	conditions := approver.csrs[csr.Name].Status.Conditions
+	require.Len(t, conditions, 1)
+	assert.Equal(t, certificatesv1.RequestConditionType("example.com/Validated"), conditions[0].Type)
+
+	// the CSR isn't approved yet, until another tool sets its Approved condition
+	approved, _ := controller.GetCertApprovalCondition(&approver.csrs[csr.Name].Status)
+	assert.False(t, approved)
+}
+
+func TestDecisionCallback(t *testing.T) {
+	validCsr := createCsr(t, CsrParams{csrName: "callback-valid", nodeName: "node-callback", dnsName: "node-callback.test.ch"})
+	invalidCsr := createCsr(t, CsrParams{
+		csrName:       "callback-invalid",
+		nodeName:      "node-callback",
+		dnsName:       "node-callback.test.ch",
+		extraDNSNames: []string{"node-callback-alt.test.ch"},
+	})
+	ignoredCsr := createCsr(t, CsrParams{csrName: "callback-ignored", nodeName: "node-callback", username: "node-callback",
+		commonName: "system:node:node-callback"})
+
+	var results []controller.ValidationResult
+
+	r := &controller.CertificateSigningRequestReconciler{
+		Config: controller.Config{
+			RegexStr:               `^[\w-]*\.test\.ch$`,
+			IPPrefixesStr:          "192.168.0.0/16",
+			MaxExpirationSeconds:   367 * 24 * 3600,
+			AllowedDNSNames:        1,
+			BypassDNSResolution:    true,
+			IgnoreNonSystemNodeCsr: true,
+			DecisionCallback:       func(result controller.ValidationResult) { results = append(results, result) },
+		},
+		Approver: &fakeCSRApprover{csrs: map[string]*certificatesv1.CertificateSigningRequest{
+			validCsr.Name:   &validCsr,
+			invalidCsr.Name: &invalidCsr,
+			ignoredCsr.Name: &ignoredCsr,
+		}},
+	}
+	rules, err := controller.CompileProviderRules(&r.Config)
+	require.Nil(t, err)
+	r.SetProviderRules(rules)
+
+	for _, name := range []string{validCsr.Name, invalidCsr.Name, ignoredCsr.Name, "callback-deleted"} {
+		_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		require.Nil(t, err)
+	}
+
+	// the CSR which couldn't be found led to no decision
+	require.Len(t, results, 3)
+	assert.Equal(t, validCsr.Name, results[0].CSRName)
+	assert.Equal(t, controller.DecisionApprove, results[0].Decision)
+	assert.Equal(t, invalidCsr.Name, results[1].CSRName)
+	assert.Equal(t, controller.DecisionDeny, results[1].Decision)
+	assert.Equal(t, "dns", results[1].FailedRule)
+	assert.Equal(t, ignoredCsr.Name, results[2].CSRName)
+	assert.Equal(t, controller.DecisionIgnore, results[2].Decision)
+	assert.Equal(t, "non-system-node", results[2].SkipReason)
+}
+
+func TestAnnotateDecisionWithFakeApprover(t *testing.T) {
+	csr := createCsr(t, CsrParams{csrName: "fake-annotated", nodeName: "node-fake", dnsName: "node-fake.test.ch"})
+	approver := &fakeCSRApprover{csrs: map[string]*certificatesv1.CertificateSigningRequest{csr.Name: &csr}}
+
+	// without ClientSet, the annotations are set through the Approver as well
+	r := &controller.CertificateSigningRequestReconciler{
+		Config: controller.Config{
+			RegexStr:             `^[\w-]*\.test\.ch$`,
+			IPPrefixesStr:        "192.168.0.0/16",
+			MaxExpirationSeconds: 367 * 24 * 3600,
+			AllowedDNSNames:      1,
+			BypassDNSResolution:  true,
+			AnnotateDecisions:    true,
+		},
+		Version:  "v1.2.3",
+		Approver: approver,
+	}
+	rules, err := controller.CompileProviderRules(&r.Config)
+	require.Nil(t, err)
+	r.SetProviderRules(rules)
+
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: csr.Name}})
+	require.Nil(t, err)
+	assert.Equal(t, []string{csr.Name}, approver.approved)
+
+	annotations := approver.csrs[csr.Name].Annotations
+	assert.Equal(t, "v1.2.3", annotations[controller.VersionAnnotation])
+	assert.NotEmpty(t, annotations[controller.ReasonAnnotation])
+	assert.NotEmpty(t, annotations[controller.DecidedAtAnnotation])
+}
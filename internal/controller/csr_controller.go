@@ -19,21 +19,38 @@ package controller
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
+	"regexp"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/google/cel-go/cel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 	"inet.af/netaddr"
 	certificatesv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // HostResolver is used to resolve a Host with the LookupHost function
@@ -41,35 +58,184 @@ type HostResolver interface {
 	LookupHost(context.Context, string) ([]string, error)
 }
 
-// Config holds all variables needed to configure the controller
+// AddrResolver is used to reverse-resolve an IP address with the LookupAddr function,
+// when Config.UseReverseDNS is set
+type AddrResolver interface {
+	LookupAddr(context.Context, string) ([]string, error)
+}
+
+// DefaultDNSResolutionTimeout is the time given to a DNS lookup when Config.DNSResolutionTimeout is not set
+const DefaultDNSResolutionTimeout = 10 * time.Second
+
+// DefaultPendingRequeueInterval is the delay after which a CSR intentionally left Pending is
+// processed again, when Config.PendingRequeueInterval is not set
+const DefaultPendingRequeueInterval = 15 * time.Second
+
+// DefaultRequeueJitter is the default share of the pending requeue interval by which each
+// requeue is randomly shortened or lengthened
+const DefaultRequeueJitter = 0.2
+
+// DefaultShutdownGracePeriod is the time given to the in-flight reconciliations to complete once the
+// controller is stopping, when Config.ShutdownGracePeriod is not set
+const DefaultShutdownGracePeriod = 30 * time.Second
+
+// Config holds all variables needed to configure the controller.
+// the compiled and sensitive fields are tagged to be omitted from the /config endpoint
 type Config struct {
 	LogLevel               int
+	LogFormat              string
 	MetricsAddr            string
 	ProbeAddr              string
+	MetricsTLSCertFile     string
+	MetricsTLSKeyFile      string
+	ProbeTLSCertFile       string
+	ProbeTLSKeyFile        string
+	EnableLeaderElection   bool
+	LeaderElectionID       string
+	LeaderElectionNS       string
+	SignerNames            []string
 	RegexStr               string
-	ProviderRegexp         func(string) bool
+	AdditionalRegexStrs    []string
+	ProviderRegexps        []func(string) bool `json:"-"`
+	DNSRegexStr            string
+	DNSRegexp              func(string) bool `json:"-"`
+	ShadowRegexStr         string
+	ShadowRegexp           func(string) bool `json:"-"`
+	NodeNameAllowList      string
+	NodeNameAllowSet       map[string]struct{} `json:"-"`
 	IPPrefixesStr          string
-	ProviderIPSet          *netaddr.IPSet
+	ProviderIPSet          *netaddr.IPSet `json:"-"`
+	IPv4PrefixesStr        string
+	ProviderIPv4Set        *netaddr.IPSet `json:"-"`
+	IPv6PrefixesStr        string
+	ProviderIPv6Set        *netaddr.IPSet `json:"-"`
+	DeniedIPPrefixesStr    string
+	ProviderDeniedIPSet    *netaddr.IPSet `json:"-"`
 	MaxExpirationSeconds   int32
-	K8sConfig              *rest.Config
-	DNSResolver            HostResolver
+	MinExpirationSeconds   int32
+	K8sConfig              *rest.Config `json:"-"`
+	DNSResolver            HostResolver `json:"-"`
+	DNSResolutionTimeout   time.Duration
+	DNSCacheTTL            time.Duration
 	BypassDNSResolution    bool
+	UseReverseDNS          bool
 	IgnoreNonSystemNodeCsr bool
+	RequireSystemNodesOrg  bool
+	DNSNameNodeAnnotation  string
 	AllowedDNSNames        int
+	AllowedIPAddresses     int
 	BypassHostnameCheck    bool
+	AllowWildcardDNS       bool
+
+	EnableClientCSRApproval bool
+	VerifyRequestorIdentity bool
+	// SkipClientRequestorReview skips the SubjectAccessReview of the client CSRs requestor, e.g. offline
+	SkipClientRequestorReview bool
+	RequireFQDNAndShortname   bool
+	DenyInsteadOfSkip         bool
+	VerifyNodeIPAddresses     bool
+	VerifyNodeDNSNames        bool
+	RequireNodeReady          bool
+	NodeLabelSelector         string
+	NodeSelector              labels.Selector `json:"-"`
+	EmitEvents                bool
+	ApprovalMessage           string
+	DenialMessageTemplate     string
+	DenialMessageTmpl         *template.Template `json:"-"`
+	DryRun                    bool
+	ConfigFile                string
+	WatchConfig               bool
+	ApprovalWindowsStr        string
+	ApprovalWindows           []ApprovalWindow `json:"-"`
+	MaxApprovalsPerMinute     int
+	AuditLogPath              string
+	MaxRetries                int
+	PendingRequeueInterval    time.Duration
+	ResyncToken               string `json:"-"`
+	TracingEndpoint           string
+	AllowedKeyAlgorithms      []string
+	MinRSAKeySize             int
+	AllowedUsages             []certificatesv1.KeyUsage
+
+	ValidateCommonNameFormat   bool
+	NodeExistenceGracePeriod   time.Duration
+	RequireResolvedIPInPrefix  bool
+	PriorityNodeLabelSelector  string
+	PriorityNodeSelector       labels.Selector `json:"-"`
+	PendingCSRsRefreshInterval time.Duration
+	Kubeconfig                 string
+	KubeContext                string
+	RejectDuplicateSANs        bool
+	DecisionWebhookURL         string `json:"-"`
+	DecisionWebhookTimeout     time.Duration
+	WebhookFailurePolicy       string
+	CELPolicy                  string
+	CELPolicyProgram           cel.Program `json:"-"`
+	PerNodeMetrics             bool
+	RelaxedRenewalMode         bool
+	ShutdownGracePeriod        time.Duration
+	RejectSpecialIPs           bool
+	OverridesConfigMap         string
+	ClientQPS                  float32
+	ClientBurst                int
+	RequireExpirationSeconds   bool
+	VerifyCloudInstanceID      bool
+	AllowPodCIDRIPs            bool
+	MaxPendingAge              time.Duration
+	AllowZonedIPv6             bool
+	VerifyRequestorAccess      bool
+	AnnotateDecisions          bool
+	RegexSelfTestFile          string
+	RegexSelfTestWarnOnly      bool
+	AllowedOrganizations       []string
+	OrganizationMatchMode      string
+	GCApprovedAfter            time.Duration
+	AllowedDNSSuffixes         []string
+	RequireAllDNSResolve       bool
+	MaxConcurrentReconciles    int
+	RejectUnexpectedSANTypes   bool
+	NodeAddressAnnotation      string
+	NodeAddressPrefixesStr     string
+	NodeAddressIPSet           *netaddr.IPSet `json:"-"`
+	DNSMismatchWarnOnly        bool
+	StartupWarmupDelay         time.Duration
+	LoadBalancerIPAnnotation   string
+	ApprovalConditionType      string
+	RequeueJitter              float64
+	MaxTotalSANs               int
+	RegexConfigMapRef          string
+	RegexConfigMapInterval     time.Duration
+	// DecisionCallback, when set, is called with the result of each decision taken on a CSR
+	// (approved, denied, ignored or dry-run), e.g. for the tests or the embedders of the controller
+	DecisionCallback func(ValidationResult) `json:"-"`
 }
 
 // CertificateSigningRequestReconciler reconciles a CertificateSigningRequest object
 type CertificateSigningRequestReconciler struct {
 	ClientSet *clientset.Clientset
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
 	Config
+	AuditLog *AuditLog   // nil unless AuditLogPath is set
+	Version  string      // recorded in the CSR annotations when AnnotateDecisions is set
+	Approver CSRApprover // defaults to the Kubernetes API, through Client and ClientSet
+
+	retries         retryCounter
+	rulesMu         sync.RWMutex  // guards the provider rules, see SetProviderRules
+	approvalLimiter *rate.Limiter // nil unless MaxApprovalsPerMinute is set
+	resync          chan event.GenericEvent
+	elected         chan struct{} // closed once the controller starts, see electionNotifier
+	overrides       configOverridesCache
+	startOnce       sync.Once // guards startedAt, the start of the StartupWarmupDelay
+	startedAt       time.Time
 }
 
 //+kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests,verbs=get;watch;list
 //+kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests/approval,verbs=update
 //+kubebuilder:rbac:groups=certificates.k8s.io,resources=signers,resourceNames="kubernetes.io/kubelet-serving",verbs=approve
+//+kubebuilder:rbac:groups=certificates.k8s.io,resources=signers,resourceNames="kubernetes.io/kube-apiserver-client-kubelet",verbs=approve
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile will perform a series of checks before deciding whether the CSR should be approved or denied
 // cyclomatic complexity is high (over 15), but this improves
@@ -78,26 +244,41 @@ type CertificateSigningRequestReconciler struct {
 //nolint:gocyclo // see above
 func (r *CertificateSigningRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, returnErr error) {
 	l := log.FromContext(ctx)
+	start := time.Now()
 
-	var csr certificatesv1.CertificateSigningRequest
-	if err := r.Client.Get(ctx, req.NamespacedName, &csr); err != nil {
+	ctx, span := startSpan(ctx, "Reconcile", attribute.String("csr.name", req.Name))
+	defer span.End()
+
+	getCtx, getSpan := startSpan(ctx, "GetCertificateSigningRequest")
+	fetched, err := r.csrApprover().Get(getCtx, req.Name)
+	getSpan.End()
+
+	if err != nil {
 		if apierrors.IsNotFound(err) {
 			// we'll ignore not-found errors, since we can get them on deleted requests.
+			r.retries.reset(req.Name)
+
 			return
 		}
 
-		l.Error(err, "Unable to get CSR with name: %s", req.Name)
+		l.Error(err, "Unable to get CSR", "name", req.Name)
 
-		return
+		return r.requeueOnError(l, req.Name, err)
 	}
 
+	csr := *fetched
+
+	span.SetAttributes(attribute.String("node", nodeNameOf(&csr)))
+
 	// baseline CSR checks - triage to ignore CSR we should process
-	if csr.Spec.SignerName != certificatesv1.KubeletServingSignerName {
-		l.V(4).Info("Ignoring non-kubelet-serving CSR.")
+	if !r.handlesSigner(csr.Spec.SignerName) {
+		l.V(1).Info("Ignoring CSR with a signer not handled by this controller.", "signerName", csr.Spec.SignerName)
+		countSkipped(skipReasonSigner)
+
 		return
 	}
 
-	if approved, denied := GetCertApprovalCondition(&csr.Status); approved || denied {
+	if approved, denied := r.approvalCondition(&csr); approved || denied {
 		l.V(3).Info("The CSR is already approved|denied. Ignoring", "approved", approved, "denied", denied)
 		return
 	}
@@ -107,83 +288,375 @@ func (r *CertificateSigningRequestReconciler) Reconcile(ctx context.Context, req
 		return
 	}
 
+	if now := time.Now(); !InApprovalWindows(r.ApprovalWindows, now) {
+		next := NextApprovalWindow(r.ApprovalWindows, now)
+		l.V(0).Info("Outside of the approval windows, leaving the CSR Pending.", "nextWindow", next)
+
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
+
+	if csr.Spec.SignerName != certificatesv1.KubeAPIServerClientKubeletSignerName {
+		if remaining := r.warmupRemaining(); remaining > 0 {
+			l.V(0).Info("Deferring the CSR relying on the Node objects while the controller warms up.", "requeueAfter", remaining)
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+	}
+
 	// actual CSR and x509 CR checks
-	x509cr, err := ParseCSR(csr.Spec.Request)
+	result, err := r.ValidateCSR(ctx, &csr)
 	if err != nil {
-		l.Error(err, fmt.Sprintf("unable to parse csr %q", csr.Name))
+		if isPending(err) && result.Priority {
+			// the CSRs of the priority nodes are processed again sooner than the others
+			l.V(0).Info("Leaving the CSR of a priority node Pending. Reason: " + result.Reason)
+			return ctrl.Result{RequeueAfter: r.jittered(r.pendingRequeueInterval() / priorityRequeueDivisor)}, nil
+		}
+
+		if !isPending(err) {
+			l.V(0).Error(err, result.Reason)
+		}
+
+		return r.requeueOnError(l, req.Name, err) // the CSR is processed again in the reconcile function
+	}
+
+	if result.Decision == DecisionIgnore {
+		countSkipped(result.SkipReason)
+		r.notifyDecision(result)
+
 		return
 	}
 
-	if !strings.HasPrefix(csr.Spec.Username, "system:node:") {
-		if r.IgnoreNonSystemNodeCsr {
-			l.V(0).Info("Ignoring a CSR with username different than system:node:")
-			return
+	valid := result.Decision == DecisionApprove
+
+	if r.DryRun {
+		// the whole validation pipeline ran, but the CSR is left untouched (i.e. Pending)
+		r.retries.reset(req.Name)
+		logDecision(l, &csr, valid, result.Reason, start, "dry_run", true)
+		countDecision(valid, result.FailedRule, true)
+		r.notifyDecision(result)
+
+		return res, nil
+	}
+
+	var reservation *rate.Reservation
+
+	if valid && !result.Priority {
+		var delay time.Duration
+		if reservation, delay = r.reserveApproval(); delay > 0 {
+			l.V(0).Info("Approvals rate limit reached, processing the CSR again later.", "requeueAfter", delay)
+			return ctrl.Result{RequeueAfter: delay}, nil
 		}
+	}
 
-		reason := "CSR Spec.Username is not prefixed with system:node:"
-		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	r.appendCondition(&csr, valid, result.Reason)
+
+	span.SetAttributes(attribute.Bool("approved", valid), attribute.String("reason", result.Reason))
+
+	// the decision is applied even when the controller started to shut down, within the grace period
+	decisionCtx, cancel := detachedContext(ctx, r.shutdownGracePeriod())
+	defer cancel()
+
+	updateCtx, updateSpan := startSpan(decisionCtx, "UpdateApproval")
+
+	if valid {
+		err = r.csrApprover().Approve(updateCtx, &csr)
+	} else {
+		err = r.csrApprover().Deny(updateCtx, &csr)
+	}
+
+	updateSpan.End()
 
-		appendCondition(&csr, false, reason)
+	if err != nil {
+		// the CSR isn't approved, its token is taken again when it gets processed once more
+		r.cancelApproval(reservation)
+	}
+
+	if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+		// The CSR has been updated or deleted since we read it.
+		// Requeue the CSR to try to reconciliate again.
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		l.Error(err, "Couldn't update the CSR to include the approval")
+		return r.requeueOnError(l, req.Name, err)
+	}
+
+	r.retries.reset(req.Name)
+	logDecision(l, &csr, valid, result.Reason, start)
+	countDecision(valid, result.FailedRule, false)
+	r.recordLastDecision(&csr, valid)
+	r.auditDecision(l, &csr, result.x509cr, valid, result.Reason)
+
+	if err = r.annotateDecision(decisionCtx, &csr, valid, result.Reason); err != nil {
+		// the decision is applied already, the CSR isn't processed again for its annotations
+		l.Error(err, "Couldn't annotate the CSR with the decision")
+	}
+
+	r.recordDecisionEvent(&csr, valid, result.Reason)
+	r.notifyDecision(result)
+
+	return res, nil
+}
+
+func (r *CertificateSigningRequestReconciler) shutdownGracePeriod() time.Duration {
+	if r.ShutdownGracePeriod <= 0 {
+		return DefaultShutdownGracePeriod
+	}
+
+	return r.ShutdownGracePeriod
+}
+
+// detachedContext returns a context which isn't canceled along with ctx (e.g. once the manager is
+// stopping), but after the timeout. the logger and the trace span of ctx are carried over.
+func detachedContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	detached := trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+	detached = log.IntoContext(detached, log.FromContext(ctx))
+
+	return context.WithTimeout(detached, timeout)
+}
+
+// logDecision logs the decision taken on the CSR with structured fields, to ease
+// the ingestion of the logs when the json format is used
+func logDecision(l logr.Logger, csr *certificatesv1.CertificateSigningRequest, valid bool, reason string,
+	start time.Time, keysAndValues ...interface{}) {
+	decision := "denied"
+	if valid {
+		decision = "approved"
+	}
+
+	l.V(0).Info("CSR decision", append([]interface{}{
+		"csr_name", csr.Name,
+		"node", nodeNameOf(csr),
+		"decision", decision,
+		"reason", reason,
+		"duration_ms", time.Since(start).Milliseconds(),
+	}, keysAndValues...)...)
+}
+
+// ServingCSRChecks runs the kubelet-serving rule set against the CSR and its
+// parsed x509 certificate request. A non-nil error means the checks could not
+// be completed and the CSR should be processed again.
+func (r *CertificateSigningRequestReconciler) ServingCSRChecks(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (valid bool, rule, reason string, err error) {
+	l := log.FromContext(ctx)
+
+	if valid, reason = r.PendingAgeCheck(csr); !valid {
+		rule = ruleStale
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if !strings.HasPrefix(csr.Spec.Username, "system:node:") {
+		rule = ruleUsername
+		reason = "CSR Spec.Username is not prefixed with system:node:"
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
 	} else if len(x509cr.DNSNames)+len(x509cr.IPAddresses) == 0 {
-		reason := "The x509 Cert Request SAN contains neither an IP address nor a DNS name"
+		rule = ruleSAN
+		reason = "The x509 Cert Request SAN contains neither an IP address nor a DNS name"
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason = r.DuplicateSANCheck(x509cr); !valid {
+		rule = ruleSAN
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason = r.UnexpectedSANTypesCheck(x509cr); !valid {
+		rule = ruleSAN
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason = r.TotalSANsCheck(x509cr); !valid {
+		rule = ruleSAN
 		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
-
-		appendCondition(&csr, false, reason)
 	} else if x509cr.Subject.CommonName != csr.Spec.Username {
-		reason := "CSR username does not match the parsed x509 certificate request commonname"
+		rule = ruleCommonName
+		reason = "CSR username does not match the parsed x509 certificate request commonname"
 		l.V(0).Info("Denying kubelet-serving CSR. Reason:"+reason,
 			"commonName", x509cr.Subject.CommonName, "specUsername", csr.Spec.Username)
-
-		appendCondition(&csr, false, reason)
-	} else if valid, reason, err := r.DNSCheck(ctx, &csr, x509cr); !valid {
+	} else if _, ok := NodeNameFromCommonName(x509cr.Subject.CommonName); r.ValidateCommonNameFormat && !ok {
+		rule = ruleCommonName
+		reason = "The x509 Cert Request CommonName " + x509cr.Subject.CommonName + " is not of the form system:node:<nodename>, " +
+			"with a node name made of lowercase alphanumerical characters, '-' and '.'"
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason = r.servingOrganizationCheck(x509cr); !valid {
+		rule = ruleOrganization
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:"+reason, "organization", x509cr.Subject.Organization)
+	} else if valid, reason, err = r.RequestorAccessCheck(ctx, csr); !valid {
 		if err != nil {
-			l.V(0).Error(err, reason)
-			return res, err // returning a non-nil error to make this request be processed again in the reconcile function
+			return valid, ruleRequestor, reason, err
 		}
+		rule = ruleRequestor
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason = r.UsageCheck(csr); !valid {
+		rule = ruleUsage
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason = r.KeyCheck(x509cr); !valid {
+		rule = ruleKey
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason, err = r.DNSCheck(ctx, csr, x509cr); !valid {
+		if err != nil {
+			return valid, ruleDNS, reason, err
+		}
+		rule = ruleDNS
 		l.V(0).Info("Denying kubelet-serving CSR. DNS checks failed. Reason:" + reason)
-
-		appendCondition(&csr, false, reason)
-	} else if valid, reason, err := r.WhitelistedIPCheck(&csr, x509cr); !valid {
+	} else if valid, reason, err = r.WhitelistedIPCheck(ctx, csr, x509cr); !valid {
 		if err != nil {
-			l.V(0).Error(err, reason)
-			return res, err // returning a non-nil error to make this request be processed again in the reconcile function
+			return valid, ruleIPPrefix, reason, err
 		}
+		rule = ruleIPPrefix
 		l.V(0).Info("Denying kubelet-serving CSR. IP whitelist check failed. Reason:" + reason)
-		appendCondition(&csr, false, reason)
-	} else if csr.Spec.ExpirationSeconds != nil && *csr.Spec.ExpirationSeconds > r.MaxExpirationSeconds {
-		reason := "CSR spec.expirationSeconds is longer than the maximum allowed expiration second"
+	} else if valid, reason, err = r.NodeIPCheck(ctx, csr, x509cr); !valid {
+		if err != nil {
+			return valid, ruleNodeIP, reason, err
+		}
+		rule = ruleNodeIP
+		l.V(0).Info("Denying kubelet-serving CSR. Node IP addresses check failed. Reason:" + reason)
+	} else if valid, reason, err = r.NodeDNSCheck(ctx, csr, x509cr); !valid {
+		if err != nil {
+			return valid, ruleNodeDNS, reason, err
+		}
+		rule = ruleNodeDNS
+		l.V(0).Info("Denying kubelet-serving CSR. Node DNS names check failed. Reason:" + reason)
+	} else if valid, reason, err = r.NodeInstanceIDCheck(ctx, csr, x509cr); !valid {
+		if err != nil {
+			return valid, ruleInstanceID, reason, err
+		}
+		rule = ruleInstanceID
+		l.V(0).Info("Denying kubelet-serving CSR. Cloud instance ID check failed. Reason:" + reason)
+	} else if valid, reason, err = r.NodeReadyCheck(ctx, csr); !valid {
+		if err != nil {
+			return valid, ruleNodeReady, reason, err
+		}
+		rule = ruleNodeReady
 		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
-
-		appendCondition(&csr, false, reason)
-	} else if valid, reason := ProviderChecks(&csr, x509cr); !valid {
+	} else if valid, reason = r.ExpirationCheck(csr); !valid {
+		rule = ruleExpiration
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason = ProviderChecks(csr, x509cr); !valid {
+		rule = ruleProvider
 		l.V(0).Info("CSR request did not pass the provider-specific tests. Reason: " + reason)
-		appendCondition(&csr, false, reason)
-	} else {
-		l.V(0).Info("CSR approved")
-		appendCondition(&csr, true, "")
+	} else if valid, reason = r.CELPolicyCheck(csr, x509cr); !valid {
+		rule = ruleCELPolicy
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
+	} else if valid, reason, err = r.DecisionWebhookCheck(ctx, csr, x509cr); !valid {
+		if err != nil {
+			return valid, ruleWebhook, reason, err
+		}
+		rule = ruleWebhook
+		l.V(0).Info("Denying kubelet-serving CSR. Reason:" + reason)
 	}
 
-	_, err = r.ClientSet.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx, req.Name, &csr, metav1.UpdateOptions{})
+	return valid, rule, reason, nil
+}
 
-	if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
-		// The CSR has been updated or deleted since we read it.
-		// Requeue the CSR to try to reconciliate again.
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		l.Error(err, "Couldn't update the CSR to include the approval")
-		return ctrl.Result{}, err
+//nolint:gochecknoglobals // compiled once, the node name is a lowercase RFC 1123 subdomain
+var commonNameRegexp = regexp.MustCompile(`^system:node:([a-z0-9.-]+)$`)
+
+// NodeNameFromCommonName extracts the node name of a system:node:<nodename> CommonName.
+// ok is false when the CommonName isn't well-formed
+func NodeNameFromCommonName(commonName string) (nodeName string, ok bool) {
+	match := commonNameRegexp.FindStringSubmatch(commonName)
+	if match == nil {
+		return "", false
 	}
 
-	return res, nil
+	return match[1], true
+}
+
+// nodeNameOf returns the name of the node requesting the CSR
+func nodeNameOf(csr *certificatesv1.CertificateSigningRequest) string {
+	if nodeName, ok := NodeNameFromCommonName(csr.Spec.Username); ok {
+		return nodeName
+	}
+
+	return strings.TrimPrefix(csr.Spec.Username, "system:node:")
+}
+
+// ExpirationCheck verifies that the CSR spec.expirationSeconds, if specified,
+// is not longer than the maximum allowed expiration seconds
+func (r *CertificateSigningRequestReconciler) ExpirationCheck(csr *certificatesv1.CertificateSigningRequest) (valid bool, reason string) {
+	defer observePhase(phaseExpiration, time.Now())
+
+	// an absent expirationSeconds leaves the certificate duration up to the signer default
+	if r.RequireExpirationSeconds && (csr.Spec.ExpirationSeconds == nil || *csr.Spec.ExpirationSeconds == 0) {
+		return false, "CSR spec.expirationSeconds must be set to request a bounded certificate duration"
+	}
+
+	if csr.Spec.ExpirationSeconds != nil && *csr.Spec.ExpirationSeconds > r.MaxExpirationSeconds {
+		return false, "CSR spec.expirationSeconds is longer than the maximum allowed expiration second"
+	}
+
+	if csr.Spec.ExpirationSeconds != nil && *csr.Spec.ExpirationSeconds < r.MinExpirationSeconds {
+		return false, fmt.Sprintf("CSR spec.expirationSeconds is shorter than the minimum allowed expiration seconds (%d)", r.MinExpirationSeconds)
+	}
+
+	return true, ""
 }
 
-func appendCondition(csr *certificatesv1.CertificateSigningRequest, approved bool, reason string) {
+// PendingAgeCheck verifies that the CSR has not been Pending for longer than the MaxPendingAge, if set.
+// a CSR without creationTimestamp (e.g. evaluated offline) is never stale
+func (r *CertificateSigningRequestReconciler) PendingAgeCheck(csr *certificatesv1.CertificateSigningRequest) (valid bool, reason string) {
+	if r.MaxPendingAge <= 0 || csr.CreationTimestamp.IsZero() {
+		return true, ""
+	}
+
+	if age := time.Since(csr.CreationTimestamp.Time); age > r.MaxPendingAge {
+		return false, fmt.Sprintf("The CSR is stale, it has been Pending for %s, longer than the maximum pending age of %s",
+			age.Round(time.Second), r.MaxPendingAge)
+	}
+
+	return true, ""
+}
+
+// handlesSigner returns true when CSRs of the given signer should be processed by this controller
+func (r *CertificateSigningRequestReconciler) handlesSigner(signerName string) bool {
+	if signerName == certificatesv1.KubeAPIServerClientKubeletSignerName {
+		return r.EnableClientCSRApproval
+	}
+
+	return containsString(r.servingSignerNames(), signerName)
+}
+
+// servingSignerNames returns the signers of the kubelet serving CSRs, all validated with the same
+// rules: kubernetes.io/kubelet-serving unless overridden through the SignerNames
+func (r *CertificateSigningRequestReconciler) servingSignerNames() []string {
+	if len(r.SignerNames) == 0 {
+		return []string{certificatesv1.KubeletServingSignerName}
+	}
+
+	return r.SignerNames
+}
+
+// recordDecisionEvent emits a Kubernetes Event on the CSR describing the approval decision
+func (r *CertificateSigningRequestReconciler) recordDecisionEvent(csr *certificatesv1.CertificateSigningRequest, approved bool, reason string) {
+	if !r.EmitEvents || r.EventRecorder == nil {
+		return
+	}
+
+	if approved {
+		r.EventRecorder.Event(csr, corev1.EventTypeNormal, "CSRApproved", "CSR complied with kubelet-csr-approver validation process")
+		return
+	}
+
+	r.EventRecorder.Event(csr, corev1.EventTypeWarning, "CSRDenied", "CSR denied by kubelet-csr-approver. Reason: "+reason)
+}
+
+// reasons of the conditions set by the controller on the kubelet-serving and kubelet-client CSRs
+const (
+	servingApprovedReason = "kubelet-serving cert validated"
+	servingDeniedReason   = "kubelet-serving cert denied"
+	clientApprovedReason  = "kubelet-client cert validated"
+	clientDeniedReason    = "kubelet-client cert denied"
+)
+
+// conditionReasons returns the reasons of the approval and denial conditions of the CSRs of the given signer
+func conditionReasons(signerName string) (approved, denied string) {
+	if signerName == certificatesv1.KubeAPIServerClientKubeletSignerName {
+		return clientApprovedReason, clientDeniedReason
+	}
+
+	return servingApprovedReason, servingDeniedReason
+}
+
+func (r *CertificateSigningRequestReconciler) appendCondition(csr *certificatesv1.CertificateSigningRequest, approved bool, reason string) {
+	approvedReason, deniedReason := conditionReasons(csr.Spec.SignerName)
+
 	if approved {
 		csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
-			Type:               certificatesv1.CertificateApproved,
+			Type:               r.approvalConditionType(),
 			Status:             corev1.ConditionTrue,
-			Reason:             "kubelet-serving cert validated",
-			Message:            "CSR complied with kubelet-csr-approver validation process",
+			Reason:             approvedReason,
+			Message:            r.approvalMessage(),
 			LastUpdateTime:     metav1.Now(),
 			LastTransitionTime: metav1.Time{},
 		})
@@ -191,17 +664,82 @@ func appendCondition(csr *certificatesv1.CertificateSigningRequest, approved boo
 		csr.Status.Conditions = append(csr.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
 			Type:               certificatesv1.CertificateDenied,
 			Status:             corev1.ConditionTrue,
-			Reason:             "kubelet-serving cert denied",
-			Message:            "CSR not complying with kubelet-csr-approver validation process. Reason: " + reason,
+			Reason:             deniedReason,
+			Message:            r.denialMessage(csr, reason),
 			LastUpdateTime:     metav1.Now(),
 			LastTransitionTime: metav1.Time{},
 		})
 	}
 }
 
+// MessageData holds the values the DenialMessageTemplate can refer to, e.g. {{.Reason}}
+type MessageData struct {
+	CSRName  string
+	NodeName string
+	Reason   string
+}
+
+func (r *CertificateSigningRequestReconciler) approvalMessage() string {
+	if r.ApprovalMessage != "" {
+		return r.ApprovalMessage
+	}
+
+	return "CSR complied with kubelet-csr-approver validation process"
+}
+
+func (r *CertificateSigningRequestReconciler) denialMessage(csr *certificatesv1.CertificateSigningRequest, reason string) string {
+	defaultMessage := "CSR not complying with kubelet-csr-approver validation process. Reason: " + reason
+	if r.DenialMessageTmpl == nil {
+		return defaultMessage
+	}
+
+	var message strings.Builder
+	if err := r.DenialMessageTmpl.Execute(&message, MessageData{
+		CSRName:  csr.Name,
+		NodeName: nodeNameOf(csr),
+		Reason:   reason,
+	}); err != nil {
+		return defaultMessage
+	}
+
+	return message.String()
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *CertificateSigningRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.approvalLimiter = newApprovalLimiter(r.MaxApprovalsPerMinute)
+	r.resync = make(chan event.GenericEvent)
+	r.elected = make(chan struct{})
+
+	if err := mgr.Add(r.warmupStarter()); err != nil {
+		return err
+	}
+
+	if err := mgr.Add(r.electionNotifier()); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&certificatesv1.CertificateSigningRequest{}).
+		Watches(&source.Channel{Source: r.resync}, &handler.EnqueueRequestForObject{}).
+		WithEventFilter(r.pendingCSRPredicate()).
+		// the approvals rate limit is shared by the concurrent reconciles
+		WithOptions(crcontroller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
+
+// pendingCSRPredicate filters out the events of the CSRs of a signer not handled by the
+// controller, and of the already approved or denied CSRs. update events are filtered on
+// the new object, so that the CSRs updated while still Pending keep being reconciled
+func (r *CertificateSigningRequestReconciler) pendingCSRPredicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		csr, ok := obj.(*certificatesv1.CertificateSigningRequest)
+		if !ok || !r.handlesSigner(csr.Spec.SignerName) {
+			return false
+		}
+
+		approved, denied := r.approvalCondition(csr)
+
+		return !approved && !denied
+	})
+}
@@ -17,13 +17,23 @@ limitations under the License.
 package controller_test
 
 import (
+	"context"
 	"net"
+	"regexp"
 	"testing"
+	"text/template"
+	"time"
 
 	"github.com/foxcpp/go-mockdns"
 	"github.com/stretchr/testify/require"
+	"github.com/thanhpk/randstr"
 	"github.com/tj/assert"
+	"inet.af/netaddr"
+	certificates_v1 "k8s.io/api/certificates/v1"
+	rbac_v1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
 )
 
 func TestValidCsrApproved(t *testing.T) {
@@ -195,6 +205,30 @@ func TestMismatchedResolvedIpsSANIps(t *testing.T) {
 
 }
 
+func TestExpirationSecondsTooSmall(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:           "expiration-seconds-too-small",
+		expirationSeconds: 1800, // half an hour, while the minimum is set to an hour
+		nodeName:          testNodeName,
+		dnsName:           testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.MinExpirationSeconds = 3600
+	defer func() { csrController.MinExpirationSeconds = 0 }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, denied)
+	assert.False(t, approved)
+}
+
 func TestExpirationSecondsTooLarge(t *testing.T) {
 	csrParams := CsrParams{
 		csrName:           "expiration-seconds",
@@ -215,6 +249,47 @@ func TestExpirationSecondsTooLarge(t *testing.T) {
 	assert.False(t, approved)
 }
 
+func TestExpirationSecondsRequired(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "expiration-seconds-required",
+		nodeName: testNodeName,
+		dnsName:  testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.RequireExpirationSeconds = true
+	defer func() { csrController.RequireExpirationSeconds = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, denied)
+	assert.False(t, approved)
+	assert.Contains(t, reason, "spec.expirationSeconds must be set")
+}
+
+func TestExpirationCheckRequiredExpirationSeconds(t *testing.T) {
+	r := controller.CertificateSigningRequestReconciler{Config: controller.Config{
+		RequireExpirationSeconds: true,
+		MaxExpirationSeconds:     367 * 24 * 3600,
+	}}
+
+	var csr certificates_v1.CertificateSigningRequest
+
+	valid, _ := r.ExpirationCheck(&csr)
+	assert.False(t, valid, "an absent expirationSeconds is denied")
+
+	expirationSeconds := int32(24 * 3600)
+	csr.Spec.ExpirationSeconds = &expirationSeconds
+	valid, _ = r.ExpirationCheck(&csr)
+	assert.True(t, valid, "an expirationSeconds within the bounds is approved")
+}
+
 func TestBypassDNSResolution(t *testing.T) {
 	csrParams := CsrParams{
 		csrName:  "dns-bypass",
@@ -311,3 +386,1029 @@ func TestIPv6WithoutDNSNotWhitelisted(t *testing.T) {
 	assert.False(t, approved)
 	assert.True(t, denied)
 }
+
+func TestClientCsrApproved(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "client-csr-approved",
+		nodeName: testNodeName,
+	}
+	csr := createCsr(t, csrParams)
+	csr.Spec.SignerName = certificates_v1.KubeAPIServerClientKubeletSignerName
+	csr.Spec.Usages = []certificates_v1.KeyUsage{
+		certificates_v1.UsageDigitalSignature,
+		certificates_v1.UsageKeyEncipherment,
+		certificates_v1.UsageClientAuth,
+	}
+
+	csrController.EnableClientCSRApproval = true
+	defer func() { csrController.EnableClientCSRApproval = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters", "system:nodes"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestClientCsrWithSANDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "client-csr-with-san",
+		nodeName: testNodeName,
+		dnsName:  testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+	csr.Spec.SignerName = certificates_v1.KubeAPIServerClientKubeletSignerName
+	csr.Spec.Usages = []certificates_v1.KeyUsage{
+		certificates_v1.UsageDigitalSignature,
+		certificates_v1.UsageKeyEncipherment,
+		certificates_v1.UsageClientAuth,
+	}
+
+	csrController.EnableClientCSRApproval = true
+	defer func() { csrController.EnableClientCSRApproval = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters", "system:nodes"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestClientCsrForAnotherNodeDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:    "client-csr-another-node",
+		nodeName:   testNodeName,
+		commonName: "system:node:another-node",
+	}
+	csr := createCsr(t, csrParams)
+	csr.Spec.SignerName = certificates_v1.KubeAPIServerClientKubeletSignerName
+	csr.Spec.Usages = []certificates_v1.KeyUsage{
+		certificates_v1.UsageDigitalSignature,
+		certificates_v1.UsageKeyEncipherment,
+		certificates_v1.UsageClientAuth,
+	}
+
+	csrController.EnableClientCSRApproval = true
+	csrController.VerifyRequestorIdentity = true
+
+	defer func() {
+		csrController.EnableClientCSRApproval = false
+		csrController.VerifyRequestorIdentity = false
+	}()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters", "system:nodes"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestClientCsrRequestorNotAuthorizedDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "client-csr-requestor-not-authorized",
+		nodeName: "client-csr-unauthorized-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz"),
+	}
+	csr := createCsr(t, csrParams)
+	csr.Spec.SignerName = certificates_v1.KubeAPIServerClientKubeletSignerName
+	csr.Spec.Usages = []certificates_v1.KeyUsage{
+		certificates_v1.UsageDigitalSignature,
+		certificates_v1.UsageKeyEncipherment,
+		certificates_v1.UsageClientAuth,
+	}
+
+	// the requestor may create CSRs, but neither the nodeclient nor the selfnodeclient subresource
+	group := "csr-creators-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	_, err := adminClientset.RbacV1().ClusterRoles().Create(testContext, &rbac_v1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: group},
+		Rules: []rbac_v1.PolicyRule{{
+			APIGroups: []string{certificates_v1.GroupName},
+			Resources: []string{"certificatesigningrequests"},
+			Verbs:     []string{"create", "get"},
+		}},
+	}, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the ClusterRole.")
+	_, err = adminClientset.RbacV1().ClusterRoleBindings().Create(testContext, &rbac_v1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: group},
+		RoleRef:    rbac_v1.RoleRef{APIGroup: rbac_v1.GroupName, Kind: "ClusterRole", Name: group},
+		Subjects:   []rbac_v1.Subject{{APIGroup: rbac_v1.GroupName, Kind: rbac_v1.GroupKind, Name: group}},
+	}, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the ClusterRoleBinding.")
+
+	csrController.EnableClientCSRApproval = true
+	defer func() { csrController.EnableClientCSRApproval = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{group, "system:nodes"})
+	_, err = nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+// blockingResolver never answers, simulating an unreachable DNS server
+type blockingResolver struct{}
+
+func (blockingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestDNSResolutionTimeout(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "dns-resolution-timeout",
+		nodeName: testNodeName,
+		dnsName:  testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+
+	csrController.DNSResolver = blockingResolver{}
+	csrController.DNSResolutionTimeout = 50 * time.Millisecond
+	defer func() {
+		csrController.DNSResolver = &dnsResolver
+		csrController.DNSResolutionTimeout = 0
+	}()
+
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(
+		testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.False(t, denied)
+}
+
+// silentBlockingResolver blocks every lookup until its context is done, without reporting
+// the context error
+type silentBlockingResolver struct{}
+
+func (silentBlockingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	<-ctx.Done()
+	return nil, nil
+}
+
+func TestDNSCheckCancelledMidLookup(t *testing.T) {
+	r := &controller.CertificateSigningRequestReconciler{Config: controller.Config{
+		RegexStr:             `^node-\w*\.test\.ch$`,
+		IPPrefixesStr:        "192.168.0.0/16",
+		AllowedDNSNames:      1,
+		DNSResolver:          silentBlockingResolver{},
+		DNSResolutionTimeout: time.Minute,
+	}}
+	rules, err := controller.CompileProviderRules(&r.Config)
+	require.Nil(t, err)
+	r.SetProviderRules(rules)
+
+	csr := createCsr(t, CsrParams{nodeName: "node-cancel", dnsName: "node-cancel.test.ch"})
+	x509cr, err := controller.ParseCSR(csr.Spec.Request)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	valid, reason, err := r.DNSCheck(ctx, &csr, x509cr)
+
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.False(t, valid)
+	assert.Contains(t, reason, "was cancelled")
+	// a nil error would deny the CSR instead of processing it again
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// staticResolver resolves every host to the same address
+type staticResolver string
+
+func (s staticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return []string{string(s)}, nil
+}
+
+func TestZonedIPv6ResolvedAddresses(t *testing.T) {
+	csr := createCsr(t, CsrParams{
+		nodeName:    "node-zoned",
+		dnsName:     "node-zoned.test.ch",
+		ipAddresses: []net.IP{net.ParseIP("fe80::1")},
+	})
+	x509cr, err := controller.ParseCSR(csr.Spec.Request)
+	require.Nil(t, err)
+
+	for _, tc := range []struct {
+		resolvedAddress string
+		allowZonedIPv6  bool
+		valid           bool
+	}{
+		{"fe80::1", false, true},
+		{"fe80::1", true, true},
+		{"fe80::1%eth0", false, false},
+		{"fe80::1%eth0", true, true},
+	} {
+		r := &controller.CertificateSigningRequestReconciler{Config: controller.Config{
+			RegexStr:           `^node-\w*\.test\.ch$`,
+			IPPrefixesStr:      "fe80::/10",
+			AllowedDNSNames:    1,
+			AllowedIPAddresses: 1,
+			DNSResolver:        staticResolver(tc.resolvedAddress),
+			AllowZonedIPv6:     tc.allowZonedIPv6,
+		}}
+		rules, err := controller.CompileProviderRules(&r.Config)
+		require.Nil(t, err)
+		r.SetProviderRules(rules)
+
+		valid, reason, err := r.DNSCheck(testContext, &csr, x509cr)
+		require.Nil(t, err)
+		assert.Equal(t, tc.valid, valid, "%s (allow-zoned-ipv6 %t): %s", tc.resolvedAddress, tc.allowZonedIPv6, reason)
+	}
+}
+
+func TestAllowedDNSSuffixes(t *testing.T) {
+	for _, tc := range []struct {
+		dnsName string
+		valid   bool
+	}{
+		{"node-suffix.prod.example.com", true},
+		{"NODE-SUFFIX.Mgmt.Example.com.", true},
+		{"node-suffix.test.ch", true}, // allowed by the provider regex
+		{"node-suffix.example.com", false},
+		{"node-suffixprod.example.com", false},
+	} {
+		csr := createCsr(t, CsrParams{
+			nodeName:    "node-suffix",
+			dnsName:     tc.dnsName,
+			ipAddresses: []net.IP{net.ParseIP("192.168.0.15")},
+		})
+		x509cr, err := controller.ParseCSR(csr.Spec.Request)
+		require.Nil(t, err)
+
+		r := &controller.CertificateSigningRequestReconciler{Config: controller.Config{
+			RegexStr:           `^node-\w*\.test\.ch$`,
+			AllowedDNSSuffixes: []string{".prod.example.com", "mgmt.example.com."},
+			IPPrefixesStr:      "192.168.0.0/16",
+			AllowedDNSNames:    1,
+			AllowedIPAddresses: 1,
+			DNSResolver:        staticResolver("192.168.0.15"),
+		}}
+		rules, err := controller.CompileProviderRules(&r.Config)
+		require.Nil(t, err)
+		r.SetProviderRules(rules)
+
+		valid, reason, err := r.DNSCheck(testContext, &csr, x509cr)
+		require.Nil(t, err)
+		assert.Equal(t, tc.valid, valid, "%s: %s", tc.dnsName, reason)
+	}
+}
+
+// mapResolver resolves the names and addresses it maps, any other lookup fails with a NXDOMAIN
+type mapResolver map[string][]string
+
+func (m mapResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := m[host]; ok {
+		return addrs, nil
+	}
+
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (m mapResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return m.LookupHost(ctx, addr)
+}
+
+func TestRequireAllDNSResolve(t *testing.T) {
+	resolver := mapResolver{
+		"node-mix.test.ch":     {"192.168.0.16"},
+		"node-mix-alt.test.ch": {"192.168.0.16"},
+		"node-mix-out.test.ch": {"10.0.0.1"},
+		"192.168.0.16":         {"node-mix.test.ch."},
+	}
+
+	for _, tc := range []struct {
+		name                    string
+		extraDNSNames           []string
+		useReverseDNS           bool
+		requireFQDNAndShortname bool
+		validWithoutOption      bool
+		valid                   bool
+	}{
+		{"every name resolves", []string{"node-mix-alt.test.ch"}, false, false, true, true},
+		{"reverse DNS with a bogus name", []string{"node-mix-bogus.test.ch"}, true, false, true, false},
+		{"reverse DNS with a name outside of the prefixes", []string{"node-mix-out.test.ch"}, true, false, true, false},
+		{"reverse DNS with resolvable names", []string{"node-mix-alt.test.ch"}, true, false, true, true},
+		{"unresolvable short name", []string{"node-mix"}, false, true, true, false},
+	} {
+		csr := createCsr(t, CsrParams{
+			nodeName:      "node-mix",
+			dnsName:       "node-mix.test.ch",
+			extraDNSNames: tc.extraDNSNames,
+			ipAddresses:   []net.IP{net.ParseIP("192.168.0.16")},
+		})
+		x509cr, err := controller.ParseCSR(csr.Spec.Request)
+		require.Nil(t, err)
+
+		for _, requireAllDNSResolve := range []bool{false, true} {
+			r := &controller.CertificateSigningRequestReconciler{Config: controller.Config{
+				RegexStr:                `^node-mix[\w-]*\.test\.ch$`,
+				IPPrefixesStr:           "192.168.0.0/16",
+				AllowedDNSNames:         2,
+				AllowedIPAddresses:      1,
+				DNSResolver:             resolver,
+				UseReverseDNS:           tc.useReverseDNS,
+				RequireFQDNAndShortname: tc.requireFQDNAndShortname,
+				RequireAllDNSResolve:    requireAllDNSResolve,
+			}}
+			rules, err := controller.CompileProviderRules(&r.Config)
+			require.Nil(t, err)
+			r.SetProviderRules(rules)
+
+			expected := tc.validWithoutOption
+			if requireAllDNSResolve {
+				expected = tc.valid
+			}
+
+			valid, reason, err := r.DNSCheck(testContext, &csr, x509cr)
+			require.Nil(t, err)
+			assert.Equal(t, expected, valid, "%s (require-all-dns-resolve %t): %s", tc.name, requireAllDNSResolve, reason)
+		}
+	}
+}
+
+func TestDNSMismatchWarnOnly(t *testing.T) {
+	resolver := mapResolver{
+		"node-warn.test.ch":    {"192.168.0.17"},
+		"node-warn-nx.test.ch": nil,
+		"192.168.0.18":         {"node-other.test.ch."},
+	}
+
+	for _, tc := range []struct {
+		name          string
+		dnsName       string
+		useReverseDNS bool
+		valid         bool
+	}{
+		{"SAN IP address not resolved", "node-warn.test.ch", false, true},
+		{"PTR record not matching", "node-warn.test.ch", true, true},
+		{"unresolvable name", "node-warn-nx.test.ch", false, false},
+	} {
+		csr := createCsr(t, CsrParams{
+			nodeName:    "node-warn",
+			dnsName:     tc.dnsName,
+			ipAddresses: []net.IP{net.ParseIP("192.168.0.18")},
+		})
+		x509cr, err := controller.ParseCSR(csr.Spec.Request)
+		require.Nil(t, err)
+
+		for _, warnOnly := range []bool{false, true} {
+			r := &controller.CertificateSigningRequestReconciler{Config: controller.Config{
+				RegexStr:            `^node-warn[\w-]*\.test\.ch$`,
+				IPPrefixesStr:       "192.168.0.0/16",
+				AllowedDNSNames:     1,
+				AllowedIPAddresses:  1,
+				DNSResolver:         resolver,
+				UseReverseDNS:       tc.useReverseDNS,
+				DNSMismatchWarnOnly: warnOnly,
+			}}
+			rules, err := controller.CompileProviderRules(&r.Config)
+			require.Nil(t, err)
+			r.SetProviderRules(rules)
+
+			valid, reason, err := r.DNSCheck(testContext, &csr, x509cr)
+			require.Nil(t, err)
+			assert.Equal(t, tc.valid && warnOnly, valid, "%s (dns-mismatch-warn-only %t): %s", tc.name, warnOnly, reason)
+		}
+	}
+}
+
+func TestAdditionalProviderRegex(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "additional-provider-regex",
+		nodeName: testNodeName,
+		dnsName:  testNodeName + ".other.ch",
+	}
+	dnsResolver.Zones[csrParams.dnsName+"."] = mockdns.Zone{
+		A: []string{"192.168.0.15"},
+	}
+
+	providerRegexps := csrController.ProviderRegexps
+	csrController.ProviderRegexps = append(providerRegexps, regexp.MustCompile(`^[\w-]*\.other\.ch$`).MatchString)
+	defer func() { csrController.ProviderRegexps = providerRegexps }()
+
+	csr := createCsr(t, csrParams)
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestDNSRegexOverridesProviderRegex(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "dns-regex-override",
+		nodeName: testNodeName,
+		dnsName:  testNodeName + ".test.ch",
+	}
+
+	csrController.DNSRegexp = regexp.MustCompile(`^[\w-]*\.internal\.test\.ch$`).MatchString
+	defer func() { csrController.DNSRegexp = nil }()
+
+	csr := createCsr(t, csrParams)
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestDecisionEventEmitted(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "decision-event",
+		ipAddresses: testNodeIpAddresses,
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.EmitEvents = true
+	defer func() { csrController.EmitEvents = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, _, _, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+
+	var eventReasons []string
+	for i := 0; i < 10 && len(eventReasons) == 0; i++ {
+		time.Sleep(250 * time.Millisecond)
+		events, err := adminClientset.CoreV1().Events(metav1.NamespaceAll).List(testContext, metav1.ListOptions{
+			FieldSelector: "involvedObject.name=" + csr.Name,
+		})
+		require.Nil(t, err, "Could not list the events")
+		for _, e := range events.Items {
+			eventReasons = append(eventReasons, e.Reason)
+		}
+	}
+	assert.Contains(t, eventReasons, "CSRApproved")
+}
+
+func TestKeyAlgorithmNotAllowed(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "key-algorithm-not-allowed",
+		nodeName: testNodeName,
+		dnsName:  testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams) // createCsr generates Ed25519 keys
+
+	csrController.AllowedKeyAlgorithms = []string{"RSA", "ECDSA"}
+	defer func() { csrController.AllowedKeyAlgorithms = nil }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestUnexpectedUsageDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "unexpected-usage",
+		nodeName: testNodeName,
+		dnsName:  testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+	csr.Spec.Usages = append(csr.Spec.Usages, certificates_v1.UsageClientAuth)
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestTooManyIPAddresses(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "too-many-ip-addresses",
+		nodeName: testNodeName,
+		ipAddresses: []net.IP{
+			{192, 168, 14, 1}, {192, 168, 14, 2}, {192, 168, 14, 3}, {192, 168, 14, 4},
+		},
+	}
+	csr := createCsr(t, csrParams)
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestIPv6PrefixesOverrideCombinedPrefixes(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "ipv6-prefixes-override",
+		ipAddresses: testNodeIpAddresses,
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	var setBuilder netaddr.IPSetBuilder
+	setBuilder.AddPrefix(netaddr.MustParseIPPrefix("fd00:cafe::/32"))
+	ipv6Set, err := setBuilder.IPSet()
+	require.Nil(t, err)
+
+	csrController.ProviderIPv6Set = ipv6Set
+	defer func() { csrController.ProviderIPv6Set = nil }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err = nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestReverseDNSMatch(t *testing.T) {
+	nodeName := "reverse-dns-match"
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.80")}
+	// no forward zone is registered: only the PTR record can validate the DNS name
+	registerPTRZone(ipAddresses[0], nodeName+".test.ch.")
+
+	csrController.UseReverseDNS = true
+	defer func() { csrController.UseReverseDNS = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestReverseDNSMismatch(t *testing.T) {
+	nodeName := "reverse-dns-mismatch"
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.81")}
+	registerDNSZone(nodeName, ipAddresses)
+	registerPTRZone(ipAddresses[0], "another-node.test.ch.")
+
+	csrController.UseReverseDNS = true
+	defer func() { csrController.UseReverseDNS = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestDryRunLeavesCsrPending(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "dry-run",
+		ipAddresses: testNodeIpAddresses,
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.DryRun = true
+	defer func() { csrController.DryRun = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, _, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.False(t, denied)
+}
+
+func TestWildcardDNSDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "wildcard-dns-denied",
+		ipAddresses: testNodeIpAddresses,
+		nodeName:    testNodeName,
+		dnsName:     "*." + testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestWildcardDNSAllowed(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "wildcard-dns-allowed",
+		ipAddresses: testNodeIpAddresses,
+		nodeName:    testNodeName,
+		dnsName:     "*." + testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.AllowWildcardDNS = true
+	defer func() { csrController.AllowWildcardDNS = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestDenialMessageTemplate(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "denial-message-template",
+		nodeName: testNodeName,
+		dnsName:  "not-the-hostname.test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.DenialMessageTmpl = template.Must(template.New("denial").Parse("{{.CSRName}} of {{.NodeName}} denied, see the runbook"))
+	defer func() { csrController.DenialMessageTmpl = nil }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, message, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+	assert.Equal(t, "denial-message-template of "+testNodeName+" denied, see the runbook", message)
+}
+
+func TestNodeNameAllowListBypassesRegex(t *testing.T) {
+	nodeName := "special-purpose"
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.82")}
+	// the SAN DNS name doesn't comply with the provider regex, ^[\w-]*\.test\.ch$
+	dnsResolver.Zones[nodeName+".special.ch."] = mockdns.Zone{A: []string{"192.168.14.82"}}
+
+	csrParams := CsrParams{
+		csrName:     "node-name-allow-list",
+		ipAddresses: ipAddresses,
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".special.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.NodeNameAllowSet = map[string]struct{}{nodeName: {}}
+	defer func() { csrController.NodeNameAllowSet = nil }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestWrongOrganizationDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:       "wrong-organization",
+		ipAddresses:   testNodeIpAddresses,
+		nodeName:      testNodeName,
+		dnsName:       testNodeName + ".test.ch",
+		organizations: []string{"system:masters"},
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.RequireSystemNodesOrg = true
+	defer func() { csrController.RequireSystemNodesOrg = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestFQDNAndShortname(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:       "fqdn-and-shortname",
+		ipAddresses:   testNodeIpAddresses,
+		nodeName:      testNodeName,
+		dnsName:       testNodeName + ".test.ch",
+		extraDNSNames: []string{testNodeName},
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.RequireFQDNAndShortname = true
+	defer func() { csrController.RequireFQDNAndShortname = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestFQDNWithoutShortnameDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "fqdn-without-shortname",
+		ipAddresses: testNodeIpAddresses,
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.RequireFQDNAndShortname = true
+	defer func() { csrController.RequireFQDNAndShortname = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestMalformedCommonNameDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "malformed-common-name",
+		ipAddresses: testNodeIpAddresses,
+		nodeName:    "Node_" + testNodeName,
+		dnsName:     "node-" + testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.ValidateCommonNameFormat = true
+	defer func() { csrController.ValidateCommonNameFormat = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+	assert.Contains(t, reason, "is not of the form system:node:<nodename>")
+}
+
+func TestCustomSignerName(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "custom-signer-name",
+		ipAddresses: testNodeIpAddresses,
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+	csr.Spec.SignerName = "example.com/kubelet-serving"
+
+	// the kubelet-serving CSRs of both signers are processed, e.g. during a signer migration
+	csrController.SignerNames = []string{certificates_v1.KubeletServingSignerName, "example.com/kubelet-serving"}
+	defer func() { csrController.SignerNames = nil }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestReverseDNSResolvedIPOutsidePrefix(t *testing.T) {
+	nodeName := "reverse-dns-outside-prefix"
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.83")}
+	registerPTRZone(ipAddresses[0], nodeName+".test.ch.")
+	// the DNS name resolves outside of the provider IP prefixes, 192.168.0.0/16 and fc00::/7
+	registerDNSZone(nodeName, []net.IP{net.ParseIP("10.14.0.83")})
+
+	csrController.UseReverseDNS = true
+	csrController.RequireResolvedIPInPrefix = true
+	defer func() {
+		csrController.UseReverseDNS = false
+		csrController.RequireResolvedIPInPrefix = false
+	}()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestDuplicateSANsDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "duplicate-sans",
+		ipAddresses: append(append([]net.IP{}, testNodeIpAddresses...), testNodeIpAddresses[0]),
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.RejectDuplicateSANs = true
+	defer func() { csrController.RejectDuplicateSANs = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+	assert.Contains(t, reason, "more than once")
+}
+
+func TestSpecialIPDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "special-ip",
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+		ipAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.RejectSpecialIPs = true
+	csrController.BypassDNSResolution = true
+	defer func() {
+		csrController.RejectSpecialIPs = false
+		csrController.BypassDNSResolution = false
+	}()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+	assert.Contains(t, reason, "loopback, link-local, multicast or unspecified")
+}
+
+func TestEmptySANDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:  "empty-san",
+		nodeName: testNodeName,
+	}
+	csr := createCsr(t, csrParams)
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+	assert.Contains(t, reason, "neither an IP address nor a DNS name")
+}
+
+func TestStaleCSRDenied(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "stale",
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+		ipAddresses: testNodeIpAddresses,
+	}
+	csr := createCsr(t, csrParams)
+
+	// any CSR is older than a nanosecond once it gets reconciled
+	csrController.MaxPendingAge = time.Nanosecond
+	defer func() { csrController.MaxPendingAge = 0 }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+	assert.Contains(t, reason, "stale")
+}
+
+func TestDecisionAnnotations(t *testing.T) {
+	csrParams := CsrParams{
+		csrName:     "annotated",
+		nodeName:    testNodeName,
+		dnsName:     testNodeName + ".test.ch",
+		ipAddresses: testNodeIpAddresses,
+	}
+	csr := createCsr(t, csrParams)
+
+	csrController.AnnotateDecisions = true
+	csrController.Version = "v1.2.3"
+	defer func() { csrController.AnnotateDecisions = false }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, _, _, err := waitCsrApprovalStatus(csr.Name)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+
+	// the annotations are patched once the approval condition is set
+	var annotations map[string]string
+	require.Eventually(t, func() bool {
+		annotated, err := adminClientset.CertificatesV1().CertificateSigningRequests().Get(testContext, csr.Name, metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+		annotations = annotated.Annotations
+
+		return annotations[controller.DecidedAtAnnotation] != ""
+	}, 3*time.Second, 250*time.Millisecond)
+
+	assert.Equal(t, "v1.2.3", annotations[controller.VersionAnnotation])
+	assert.Contains(t, annotations[controller.ReasonAnnotation], "complied")
+	_, err = time.Parse(time.RFC3339, annotations[controller.DecidedAtAnnotation])
+	assert.NoError(t, err)
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Decision is the outcome of the validation of a CSR
+type Decision string
+
+// decisions of the CSR validation
+const (
+	DecisionApprove Decision = "approve"
+	DecisionDeny    Decision = "deny"
+	// DecisionIgnore leaves the CSR Pending, e.g. for another controller
+	DecisionIgnore Decision = "ignore"
+)
+
+// ValidationResult is the outcome of ValidateCSR, which Reconcile acts on
+type ValidationResult struct {
+	CSRName  string
+	Decision Decision
+	// Reason explains the denial, or the error when ValidateCSR returns one
+	Reason string
+	// FailedRule is the validation rule the denied CSR failed, e.g. "dns"
+	FailedRule string
+	// Priority is set for the CSRs of the nodes matching the PriorityNodeSelector
+	Priority bool
+	// SkipReason tells why the ignored CSR was skipped, e.g. "node-selector"
+	SkipReason string
+
+	x509cr *x509.CertificateRequest // nil when the CSR request couldn't be parsed
+}
+
+// ValidateCSR verifies the signature of the CSR request and runs the checks of the CSR signer,
+// and returns the decision taken on the CSR.
+// an error is returned when no decision could be taken yet, e.g. because of an unavailable
+// dependency or of a check leaving the CSR Pending: the CSR must be processed again later.
+func (r *CertificateSigningRequestReconciler) ValidateCSR(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest) (result ValidationResult, err error) {
+	l := log.FromContext(ctx)
+
+	result.CSRName = csr.Name
+	result.x509cr, err = ParseCSR(csr.Spec.Request)
+
+	var signatureErr error
+	if err == nil {
+		signatureErr = result.x509cr.CheckSignature()
+	}
+
+	switch {
+	case err != nil:
+		l.Error(err, fmt.Sprintf("unable to parse csr %q", csr.Name))
+
+		if !r.DenyInsteadOfSkip {
+			result.Decision, result.SkipReason = DecisionIgnore, skipReasonParse
+
+			return result, nil
+		}
+
+		result.FailedRule = ruleParse
+		result.Reason = "The CSR spec.request could not be parsed as a x509 Cert Request: " + err.Error()
+		l.V(0).Info("Denying CSR. Reason:" + result.Reason)
+	case signatureErr != nil:
+		// the API server usually rejects such CSRs already, the explicit denial makes the reason visible
+		result.FailedRule = ruleSignature
+		result.Reason = "The signature of the x509 Cert Request doesn't verify against its public key: " + signatureErr.Error()
+		l.V(0).Info("Denying CSR. Reason:" + result.Reason)
+	case csr.Spec.SignerName == certificatesv1.KubeAPIServerClientKubeletSignerName:
+		var valid bool
+		if valid, result.FailedRule, result.Reason, err = r.ClientCSRChecks(ctx, csr, result.x509cr); err != nil {
+			return result, err
+		} else if valid {
+			result.Decision = DecisionApprove
+			return result, nil
+		}
+
+		l.V(0).Info("Denying kube-apiserver-client-kubelet CSR. Reason:" + result.Reason)
+	default:
+		return r.validateServingCSR(ctx, csr, result.x509cr)
+	}
+
+	result.Decision = DecisionDeny
+
+	return result, nil
+}
+
+// validateServingCSR runs the checks of the kubelet-serving CSRs, once the CSR Node is selected
+// and its configuration overrides applied
+func (r *CertificateSigningRequestReconciler) validateServingCSR(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest, x509cr *x509.CertificateRequest) (result ValidationResult, err error) {
+	l := log.FromContext(ctx)
+	result.CSRName, result.x509cr = csr.Name, x509cr
+
+	if !strings.HasPrefix(csr.Spec.Username, "system:node:") && r.IgnoreNonSystemNodeCsr {
+		l.V(0).Info("Ignoring a CSR with username different than system:node:")
+
+		result.Decision, result.SkipReason = DecisionIgnore, skipReasonNonSystemNode
+
+		return result, nil
+	}
+
+	selected, err := r.NodeSelected(ctx, csr)
+	if err != nil {
+		result.Reason = "Unable to retrieve the Node object of the CSR requestor"
+		return result, err
+	} else if !selected {
+		l.V(0).Info("Ignoring a CSR whose Node doesn't match the node label selector")
+
+		result.Decision, result.SkipReason = DecisionIgnore, skipReasonNodeSelector
+
+		return result, nil
+	}
+
+	result.Priority = r.NodePrioritized(ctx, csr)
+
+	checker, err := r.overriddenReconciler(ctx, csr)
+	if err != nil {
+		result.Reason = "Unable to apply the configuration overrides of the CSR Node"
+		return result, err
+	}
+
+	var valid bool
+	if valid, result.FailedRule, result.Reason, err = checker.ServingCSRChecks(ctx, csr, x509cr); err != nil {
+		return result, err
+	}
+
+	result.Decision = DecisionDeny
+	if valid {
+		result.Decision = DecisionApprove
+	}
+
+	return result, nil
+}
+
+// notifyDecision passes the result of the decision taken on a CSR to the DecisionCallback, if any
+func (r *CertificateSigningRequestReconciler) notifyDecision(result ValidationResult) {
+	if r.DecisionCallback != nil {
+		r.DecisionCallback(result)
+	}
+}
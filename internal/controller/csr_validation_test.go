@@ -0,0 +1,88 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestValidateCSR(t *testing.T) {
+	r := &controller.CertificateSigningRequestReconciler{Config: controller.Config{
+		RegexStr:               `^[\w-]*\.test\.ch$`,
+		IPPrefixesStr:          "192.168.0.0/16",
+		MaxExpirationSeconds:   367 * 24 * 3600,
+		AllowedDNSNames:        1,
+		BypassDNSResolution:    true,
+		IgnoreNonSystemNodeCsr: true,
+	}}
+	rules, err := controller.CompileProviderRules(&r.Config)
+	require.Nil(t, err)
+	r.SetProviderRules(rules)
+
+	unparsable := createCsr(t, CsrParams{nodeName: "node-validate"})
+	unparsable.Spec.Request = []byte("not a certificate request")
+
+	for _, tc := range []struct {
+		name              string
+		params            CsrParams
+		denyInsteadOfSkip bool
+		decision          controller.Decision
+		failedRule        string
+		skipReason        string
+	}{
+		{"valid CSR", CsrParams{nodeName: "node-validate", dnsName: "node-validate.test.ch"}, false, controller.DecisionApprove, "", ""},
+		{"too many DNS names", CsrParams{nodeName: "node-validate", dnsName: "node-validate.test.ch",
+			extraDNSNames: []string{"node-validate-alt.test.ch"}}, false, controller.DecisionDeny, "dns", ""},
+		{"username without the system:node: prefix", CsrParams{nodeName: "node-validate", username: "node-validate",
+			commonName: "system:node:node-validate"}, false, controller.DecisionIgnore, "", "non-system-node"},
+		{"unparsable request", CsrParams{}, false, controller.DecisionIgnore, "", "parse"},
+		{"unparsable request denied", CsrParams{}, true, controller.DecisionDeny, "parse", ""},
+	} {
+		csr := unparsable
+		if tc.params.nodeName != "" {
+			csr = createCsr(t, tc.params)
+		}
+
+		r.DenyInsteadOfSkip = tc.denyInsteadOfSkip
+
+		result, err := r.ValidateCSR(context.Background(), &csr)
+		require.Nil(t, err, tc.name)
+		assert.Equal(t, tc.decision, result.Decision, "%s: %s", tc.name, result.Reason)
+		assert.Equal(t, tc.failedRule, result.FailedRule, tc.name)
+		assert.Equal(t, tc.skipReason, result.SkipReason, tc.name)
+	}
+}
+
+func TestValidateCSRTamperedSignature(t *testing.T) {
+	r := &controller.CertificateSigningRequestReconciler{Config: controller.Config{
+		RegexStr:             `^[\w-]*\.test\.ch$`,
+		IPPrefixesStr:        "192.168.0.0/16",
+		MaxExpirationSeconds: 367 * 24 * 3600,
+		AllowedDNSNames:      1,
+		BypassDNSResolution:  true,
+	}}
+	rules, err := controller.CompileProviderRules(&r.Config)
+	require.Nil(t, err)
+	r.SetProviderRules(rules)
+
+	csr := createCsr(t, CsrParams{nodeName: "node-tampered", dnsName: "node-tampered.test.ch"})
+	result, err := r.ValidateCSR(context.Background(), &csr)
+	require.Nil(t, err)
+	require.Equal(t, controller.DecisionApprove, result.Decision, result.Reason)
+
+	// the signature ends the DER encoding, altering its last byte keeps the request parseable
+	block, _ := pem.Decode(csr.Spec.Request)
+	block.Bytes[len(block.Bytes)-1] ^= 0xff
+	csr.Spec.Request = pem.EncodeToMemory(block)
+
+	result, err = r.ValidateCSR(context.Background(), &csr)
+	require.Nil(t, err)
+	assert.Equal(t, controller.DecisionDeny, result.Decision)
+	assert.Equal(t, "signature", result.FailedRule)
+	assert.Contains(t, result.Reason, "doesn't verify")
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+//+kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests,verbs=patch
+
+// annotations recording the decision metadata on the CSRs, when AnnotateDecisions is set
+const (
+	ReasonAnnotation    = "csr-approver/reason"
+	VersionAnnotation   = "csr-approver/version"
+	DecidedAtAnnotation = "csr-approver/decided-at"
+)
+
+// annotateDecision records the reason, the controller version and the time of the decision in the CSR
+// annotations through the CSRApprover, once the decision is applied.
+func (r *CertificateSigningRequestReconciler) annotateDecision(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest, approved bool, reason string) error {
+	if !r.AnnotateDecisions {
+		return nil
+	}
+
+	if approved {
+		reason = r.approvalMessage()
+	}
+
+	ctx, span := startSpan(ctx, "AnnotateDecision")
+	defer span.End()
+
+	return r.csrApprover().Annotate(ctx, csr, map[string]string{
+		ReasonAnnotation:    reason,
+		VersionAnnotation:   r.Version,
+		DecidedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DecisionWebhookAPIVersion is the version of the decision webhook request and response schema.
+// it is bumped on every incompatible change of the schema
+const DecisionWebhookAPIVersion = "kubelet-csr-approver/v1"
+
+// DefaultDecisionWebhookTimeout is the time given to the decision webhook when Config.DecisionWebhookTimeout is not set
+const DefaultDecisionWebhookTimeout = 5 * time.Second
+
+// webhook failure policies, i.e. what happens to a CSR when the decision webhook can't be reached
+// or returns an invalid response
+const (
+	WebhookFailurePolicyFail   = "Fail"   // the CSR is left Pending and processed again later
+	WebhookFailurePolicyIgnore = "Ignore" // the webhook verdict is skipped, the CSR is approved
+)
+
+// maxDecisionWebhookResponseSize bounds the size of the decision webhook responses which are read
+const maxDecisionWebhookResponseSize = 1 << 20
+
+// DecisionWebhookRequest is POSTed as JSON to the decision webhook, once a CSR passed the built-in checks
+type DecisionWebhookRequest struct {
+	APIVersion        string   `json:"api_version"`
+	CSRName           string   `json:"csr_name"`
+	SignerName        string   `json:"signer_name"`
+	Node              string   `json:"node"`
+	Requestor         string   `json:"requestor"`
+	Groups            []string `json:"groups"`
+	DNSNames          []string `json:"dns_names"`
+	IPAddresses       []string `json:"ip_addresses"`
+	ExpirationSeconds *int32   `json:"expiration_seconds,omitempty"`
+}
+
+// DecisionWebhookResponse is the verdict of the decision webhook, expected as JSON with a 200 status code
+type DecisionWebhookResponse struct {
+	APIVersion string `json:"api_version"`
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason"`
+}
+
+// DecisionWebhookCheck asks the decision webhook whether the CSR is to be approved. when the webhook
+// fails, the WebhookFailurePolicy either returns an error or approves the CSR
+func (r *CertificateSigningRequestReconciler) DecisionWebhookCheck(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
+	if r.DecisionWebhookURL == "" {
+		return true, "", nil
+	}
+
+	ctx, span := startSpan(ctx, "DecisionWebhook")
+	defer span.End()
+
+	response, err := r.callDecisionWebhook(ctx, csr, x509cr)
+	if err != nil {
+		if r.WebhookFailurePolicy == WebhookFailurePolicyIgnore {
+			log.FromContext(ctx).Error(err, "The decision webhook failed, ignoring its verdict as per the webhook failure policy")
+			return true, "", nil
+		}
+
+		return false, "Unable to get the verdict of the decision webhook", err
+	}
+
+	if !response.Allowed {
+		reason = "The decision webhook denied the CSR"
+		if response.Reason != "" {
+			reason += ". Reason: " + response.Reason
+		}
+
+		return false, reason, nil
+	}
+
+	return true, "", nil
+}
+
+func (r *CertificateSigningRequestReconciler) callDecisionWebhook(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (*DecisionWebhookResponse, error) {
+	request := DecisionWebhookRequest{
+		APIVersion:        DecisionWebhookAPIVersion,
+		CSRName:           csr.Name,
+		SignerName:        csr.Spec.SignerName,
+		Node:              nodeNameOf(csr),
+		Requestor:         csr.Spec.Username,
+		Groups:            csr.Spec.Groups,
+		DNSNames:          x509cr.DNSNames,
+		ExpirationSeconds: csr.Spec.ExpirationSeconds,
+	}
+
+	for _, ip := range x509cr.IPAddresses {
+		request.IPAddresses = append(request.IPAddresses, ip.String())
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := r.DecisionWebhookTimeout
+	if timeout <= 0 {
+		timeout = DefaultDecisionWebhookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, r.DecisionWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := http.DefaultClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the decision webhook returned the status code %d", httpResponse.StatusCode)
+	}
+
+	var response DecisionWebhookResponse
+	if err := json.NewDecoder(io.LimitReader(httpResponse.Body, maxDecisionWebhookResponseSize)).Decode(&response); err != nil {
+		return nil, fmt.Errorf("unable to decode the decision webhook response: %w", err)
+	}
+
+	if response.APIVersion != DecisionWebhookAPIVersion {
+		return nil, fmt.Errorf("the decision webhook responded with the api_version %q instead of %q",
+			response.APIVersion, DecisionWebhookAPIVersion)
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,67 @@
+package controller_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	certificatesv1 "k8s.io/api/certificates/v1"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestDecisionWebhookCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var request controller.DecisionWebhookRequest
+		require.Nil(t, json.NewDecoder(req.Body).Decode(&request))
+		assert.Equal(t, controller.DecisionWebhookAPIVersion, request.APIVersion)
+
+		_ = json.NewEncoder(w).Encode(controller.DecisionWebhookResponse{
+			APIVersion: controller.DecisionWebhookAPIVersion,
+			Allowed:    request.Node != "decommissioned",
+			Reason:     "the node is being decommissioned",
+		})
+	}))
+	defer server.Close()
+
+	r := controller.CertificateSigningRequestReconciler{Config: controller.Config{DecisionWebhookURL: server.URL}}
+	x509cr := &x509.CertificateRequest{DNSNames: []string{"node1.example.com"}}
+
+	csr := &certificatesv1.CertificateSigningRequest{Spec: certificatesv1.CertificateSigningRequestSpec{Username: "system:node:node1"}}
+	valid, _, err := r.DecisionWebhookCheck(context.Background(), csr, x509cr)
+	require.Nil(t, err)
+	assert.True(t, valid)
+
+	csr.Spec.Username = "system:node:decommissioned"
+	valid, reason, err := r.DecisionWebhookCheck(context.Background(), csr, x509cr)
+	require.Nil(t, err)
+	assert.False(t, valid)
+	assert.Contains(t, reason, "the node is being decommissioned")
+}
+
+func TestDecisionWebhookFailurePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	r := controller.CertificateSigningRequestReconciler{Config: controller.Config{
+		DecisionWebhookURL:   server.URL,
+		WebhookFailurePolicy: controller.WebhookFailurePolicyFail,
+	}}
+	csr := &certificatesv1.CertificateSigningRequest{}
+
+	valid, _, err := r.DecisionWebhookCheck(context.Background(), csr, &x509.CertificateRequest{})
+	assert.NotNil(t, err)
+	assert.False(t, valid)
+
+	r.WebhookFailurePolicy = controller.WebhookFailurePolicyIgnore
+	valid, _, err = r.DecisionWebhookCheck(context.Background(), csr, &x509.CertificateRequest{})
+	assert.Nil(t, err)
+	assert.True(t, valid)
+}
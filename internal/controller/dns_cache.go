@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CachingResolver is a HostResolver (and AddrResolver) keeping the successful lookups of
+// the wrapped resolver in memory for a fixed TTL
+type CachingResolver struct {
+	resolver HostResolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// NewCachingResolver wraps resolver with an in-memory cache whose entries expire after ttl
+func NewCachingResolver(resolver HostResolver, ttl time.Duration) *CachingResolver {
+	return &CachingResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  make(map[string]cacheEntry),
+	}
+}
+
+// LookupHost returns the cached addresses of host, or resolves it with the wrapped resolver
+func (c *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return c.lookup("host:"+host, func() ([]string, error) {
+		return c.resolver.LookupHost(ctx, host)
+	})
+}
+
+// LookupAddr returns the cached names of addr, or reverse-resolves it with the
+// wrapped resolver when the latter implements AddrResolver
+func (c *CachingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	addrResolver, ok := c.resolver.(AddrResolver)
+	if !ok {
+		return nil, fmt.Errorf("the wrapped resolver doesn't support reverse lookups")
+	}
+
+	return c.lookup("addr:"+addr, func() ([]string, error) {
+		return addrResolver.LookupAddr(ctx, addr)
+	})
+}
+
+func (c *CachingResolver) lookup(key string, resolve func() ([]string, error)) ([]string, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, found := c.entries[key]
+	c.mu.Unlock()
+
+	if found && now.Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := resolve()
+	if err != nil || len(addrs) == 0 {
+		return addrs, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// expired entries are purged on insertion, to bound the size of the cache
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = cacheEntry{addrs: addrs, expires: now.Add(c.ttl)}
+
+	return addrs, nil
+}
@@ -0,0 +1,45 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+// countingResolver resolves every host to the same address and counts the lookups
+type countingResolver struct {
+	lookups int
+}
+
+func (c *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	c.lookups++
+	return []string{"192.168.14.34"}, nil
+}
+
+func TestCachingResolver(t *testing.T) {
+	backend := &countingResolver{}
+	resolver := controller.NewCachingResolver(backend, 100*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		addrs, err := resolver.LookupHost(context.Background(), "node.test.ch")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"192.168.14.34"}, addrs)
+	}
+
+	assert.Equal(t, 1, backend.lookups)
+
+	_, err := resolver.LookupHost(context.Background(), "other-node.test.ch")
+	require.Nil(t, err)
+	assert.Equal(t, 2, backend.lookups)
+
+	time.Sleep(150 * time.Millisecond)
+
+	_, err = resolver.LookupHost(context.Background(), "node.test.ch")
+	require.Nil(t, err)
+	assert.Equal(t, 3, backend.lookups)
+}
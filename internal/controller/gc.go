@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests,verbs=delete
+
+// DefaultGCInterval is the interval at which the approved CSRs are garbage collected
+const DefaultGCInterval = 10 * time.Minute
+
+// ApprovedCSRsCollector is a manager Runnable deleting, every Interval, the CSRs of the handled
+// signers which were approved by the controller, whose certificate has been issued and which
+// were created more than GCApprovedAfter ago
+type ApprovedCSRsCollector struct {
+	Reconciler *CertificateSigningRequestReconciler
+	Interval   time.Duration
+	Log        logr.Logger
+}
+
+// NeedLeaderElection returns true, for a single replica to delete the CSRs
+func (c *ApprovedCSRsCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Start collects the approved CSRs until ctx is done
+func (c *ApprovedCSRsCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := c.Reconciler.collectApprovedCSRs(ctx); err != nil {
+			c.Log.Error(err, "unable to garbage collect the approved CSRs", "deleted", deleted)
+		} else if deleted > 0 {
+			c.Log.V(0).Info("Approved CSRs garbage collected", "deleted", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectApprovedCSRs deletes the issued CSRs approved by the controller, once older than GCApprovedAfter
+func (r *CertificateSigningRequestReconciler) collectApprovedCSRs(ctx context.Context) (deleted int, err error) {
+	var csrList certificatesv1.CertificateSigningRequestList
+	if err := r.Client.List(ctx, &csrList); err != nil {
+		return 0, err
+	}
+
+	for i := range csrList.Items {
+		csr := &csrList.Items[i]
+		if !r.handlesSigner(csr.Spec.SignerName) || !r.approvedByController(csr) || len(csr.Status.Certificate) == 0 ||
+			time.Since(csr.CreationTimestamp.Time) < r.GCApprovedAfter {
+			continue
+		}
+
+		// the UID precondition prevents deleting a CSR recreated with the same name in the meantime
+		err := r.Client.Delete(ctx, csr, client.Preconditions{UID: &csr.UID})
+		if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+			continue
+		} else if err != nil {
+			return deleted, err
+		}
+
+		deleted++
+
+		collectedCSRs.Inc()
+	}
+
+	return deleted, nil
+}
+
+// approvedByController returns true when the CSR has an Approved (or ApprovalConditionType) condition
+// set by the controller, i.e. whose reason is exactly the approval reason of the CSR signer
+func (r *CertificateSigningRequestReconciler) approvedByController(csr *certificatesv1.CertificateSigningRequest) bool {
+	approvedReason, _ := conditionReasons(csr.Spec.SignerName)
+
+	for _, c := range csr.Status.Conditions {
+		if (c.Type == certificatesv1.CertificateApproved || c.Type == r.approvalConditionType()) && c.Reason == approvedReason {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func issuedCSR(name, reason string, certificate []byte) *certificatesv1.CertificateSigningRequest {
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			UID:               types.UID(name),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			SignerName: certificatesv1.KubeletServingSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageServerAuth},
+		},
+		Status: certificatesv1.CertificateSigningRequestStatus{
+			Conditions: []certificatesv1.CertificateSigningRequestCondition{{
+				Type:   certificatesv1.CertificateApproved,
+				Status: corev1.ConditionTrue,
+				Reason: reason,
+			}},
+			Certificate: certificate,
+		},
+	}
+}
+
+func TestApprovedCSRsCollectorExactReason(t *testing.T) {
+	certificate := []byte("issued")
+	approved := issuedCSR("gc-approved", "kubelet-serving cert validated", certificate)
+	otherApprover := issuedCSR("gc-other-approver", "other-approver kubelet-serving cert validated", certificate)
+	pending := issuedCSR("gc-not-issued", "kubelet-serving cert validated", nil)
+
+	k8sFakeClient := fake.NewClientBuilder().WithObjects(approved, otherApprover, pending).Build()
+	r := &controller.CertificateSigningRequestReconciler{
+		Client: k8sFakeClient,
+		Config: controller.Config{GCApprovedAfter: time.Minute},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // a single collection run
+	collector := controller.ApprovedCSRsCollector{Reconciler: r, Interval: time.Minute, Log: logr.Discard()}
+	require.Nil(t, collector.Start(ctx))
+
+	var csr certificatesv1.CertificateSigningRequest
+	err := k8sFakeClient.Get(context.Background(), types.NamespacedName{Name: approved.Name}, &csr)
+	require.True(t, apierrors.IsNotFound(err), "the CSR approved by the controller should have been deleted")
+
+	for _, name := range []string{otherApprover.Name, pending.Name} {
+		require.Nil(t, k8sFakeClient.Get(context.Background(), types.NamespacedName{Name: name}, &csr), name)
+	}
+}
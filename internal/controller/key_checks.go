@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// KnownKeyAlgorithms lists the public key algorithm names accepted in Config.AllowedKeyAlgorithms
+//
+//nolint:gochecknoglobals // read-only lookup table
+var KnownKeyAlgorithms = []string{x509.RSA.String(), x509.ECDSA.String(), x509.Ed25519.String()}
+
+// DefaultServingUsages are the key usages a kubelet-serving CSR may request when Config.AllowedUsages is not set
+//
+//nolint:gochecknoglobals // read-only lookup table
+var DefaultServingUsages = []certificatesv1.KeyUsage{
+	certificatesv1.UsageDigitalSignature,
+	certificatesv1.UsageKeyEncipherment,
+	certificatesv1.UsageServerAuth,
+}
+
+// ClientUsages are the key usages a kube-apiserver-client-kubelet CSR may request
+//
+//nolint:gochecknoglobals // read-only lookup table
+var ClientUsages = []certificatesv1.KeyUsage{
+	certificatesv1.UsageDigitalSignature,
+	certificatesv1.UsageKeyEncipherment,
+	certificatesv1.UsageClientAuth,
+}
+
+// ClientUsageCheck verifies that a kube-apiserver-client-kubelet CSR doesn't request any key usage
+// outside the ClientUsages, e.g. a server auth usage
+func (r *CertificateSigningRequestReconciler) ClientUsageCheck(csr *certificatesv1.CertificateSigningRequest) (valid bool, reason string) {
+	for _, usage := range csr.Spec.Usages {
+		if !usageAllowed(usage, ClientUsages) {
+			return false, fmt.Sprintf("The CSR requests the key usage %q, which is not part of the allowed usages %v", usage, ClientUsages)
+		}
+	}
+
+	return true, ""
+}
+
+// UsageCheck verifies that the CSR doesn't request any key usage outside the allowed ones,
+// preventing a serving CSR from being used to get e.g. a client certificate
+func (r *CertificateSigningRequestReconciler) UsageCheck(csr *certificatesv1.CertificateSigningRequest) (valid bool, reason string) {
+	allowedUsages := r.AllowedUsages
+	if len(allowedUsages) == 0 {
+		allowedUsages = DefaultServingUsages
+	}
+
+	for _, usage := range csr.Spec.Usages {
+		if !usageAllowed(usage, allowedUsages) {
+			return false, fmt.Sprintf("The CSR requests the key usage %q, which is not part of the allowed usages %v", usage, allowedUsages)
+		}
+	}
+
+	return true, ""
+}
+
+func usageAllowed(usage certificatesv1.KeyUsage, allowedUsages []certificatesv1.KeyUsage) bool {
+	for _, allowed := range allowedUsages {
+		if usage == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KeyCheck verifies that the public key of the x509 CR uses one of the allowed
+// algorithms and, for RSA keys, is not shorter than the minimal key size
+func (r *CertificateSigningRequestReconciler) KeyCheck(x509cr *x509.CertificateRequest) (valid bool, reason string) {
+	if len(r.AllowedKeyAlgorithms) > 0 && !r.keyAlgorithmAllowed(x509cr.PublicKeyAlgorithm) {
+		return false, fmt.Sprintf("The x509 Cert Request public key algorithm %s is not part of the allowed algorithms %v",
+			x509cr.PublicKeyAlgorithm, r.AllowedKeyAlgorithms)
+	}
+
+	if rsaKey, ok := x509cr.PublicKey.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < r.MinRSAKeySize {
+		return false, fmt.Sprintf("The x509 Cert Request RSA key size (%d bits) is smaller than the minimum of %d bits",
+			rsaKey.N.BitLen(), r.MinRSAKeySize)
+	}
+
+	return true, ""
+}
+
+func (r *CertificateSigningRequestReconciler) keyAlgorithmAllowed(algorithm x509.PublicKeyAlgorithm) bool {
+	for _, allowed := range r.AllowedKeyAlgorithms {
+		if strings.EqualFold(allowed, algorithm.String()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isKnownKeyAlgorithm(algorithm string) bool {
+	for _, known := range KnownKeyAlgorithms {
+		if strings.EqualFold(algorithm, known) {
+			return true
+		}
+	}
+
+	return false
+}
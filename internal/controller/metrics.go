@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+//nolint:gochecknoglobals // prometheus collectors are registered once, at package initialization
+var (
+	approvedCSRs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csr_approver_approved_total",
+		Help: "Number of CSRs approved by the kubelet-csr-approver, or that would have been in dry-run mode",
+	}, []string{"dry_run"})
+	deniedCSRs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csr_approver_denied_total",
+		Help: "Number of CSRs denied by the kubelet-csr-approver (or that would have been in dry-run mode), by failed validation rule",
+	}, []string{"reason", "dry_run"})
+	ignoredCSRs = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "csr_approver_ignored_total",
+		Help: "Number of CSRs ignored (i.e. left Pending) by the kubelet-csr-approver",
+	})
+	skippedCSRs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csr_approver_skipped_total",
+		Help: "Number of CSRs ignored (i.e. left Pending) by the kubelet-csr-approver, by reason",
+	}, []string{"reason"})
+	retriesExhausted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "csr_approver_retries_exhausted_total",
+		Help: "Number of CSRs given up on (i.e. left Pending) after failing more than max-retries times in a row",
+	})
+	dnsFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csr_approver_dns_failures_total",
+		Help: "Number of failed DNS resolutions of the CSR SAN DNS names (or IP addresses, in reverse DNS mode), by reason",
+	}, []string{"reason"})
+	shadowRegexMismatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "csr_approver_shadow_regex_mismatch_total",
+		Help: "Number of CSR evaluations with a SAN DNS name not matching the shadow provider regex, which never denies",
+	})
+	shadowDNSMismatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "csr_approver_shadow_dns_mismatch_total",
+		Help: "Number of SAN IP addresses not matching the DNS records, tolerated with dns-mismatch-warn-only",
+	})
+	collectedCSRs = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "csr_approver_gc_deleted_total",
+		Help: "Number of approved CSRs deleted by the garbage collection of the kubelet-csr-approver",
+	})
+	approvalRateLimitSaturation = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "csr_approver_approval_rate_limit_saturation",
+		Help: "Share of the approvals rate limit bucket used, as of the last approval. 1 means CSRs are being delayed",
+	})
+	pendingCSRsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "csr_approver_pending_csrs",
+		Help: "Number of Pending CSRs of the signers handled by the kubelet-csr-approver, refreshed periodically",
+	})
+	lastDecisionTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "csr_approver_last_decision_timestamp",
+		Help: "Unix timestamp of the last approval or denial of a CSR, by node unless the per-node metrics are disabled",
+	}, []string{"node", "decision"})
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "csr_approver_build_info",
+		Help: "Always 1, labelled with the commit, ref and version the running kubelet-csr-approver was built from",
+	}, []string{"commit", "ref", "version"})
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "csr_approver_reconcile_duration_seconds",
+		Help: "Time spent in each of the CSR validation phases",
+		// DNS lookups can take up to multiple seconds when they time out
+		Buckets: []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"phase"})
+)
+
+// validation phases, used to label the reconcile duration histogram
+const (
+	phaseRegex      = "regex"
+	phaseIP         = "ip"
+	phaseDNS        = "dns"
+	phaseExpiration = "expiration"
+)
+
+// reasons of the DNS resolution failures, used to label the DNS failures counter
+const (
+	dnsFailureTimeout  = "timeout"
+	dnsFailureNXDomain = "nxdomain"
+	dnsFailureError    = "error"
+	dnsFailureMismatch = "mismatch"
+)
+
+// reasons of the skipped CSRs, used to label the skipped CSRs counter
+const (
+	skipReasonSigner        = "signer"
+	skipReasonNonSystemNode = "non-system-node"
+	skipReasonNodeSelector  = "node-selector"
+	skipReasonParse         = "parse"
+)
+
+// observePhase records the time elapsed since start for the given validation phase
+func observePhase(phase string, start time.Time) {
+	reconcileDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+// countDecision increments the approved or denied counter, labelled with the failed rule and the dry-run mode
+func countDecision(valid bool, rule string, dryRun bool) {
+	if valid {
+		approvedCSRs.WithLabelValues(strconv.FormatBool(dryRun)).Inc()
+	} else {
+		deniedCSRs.WithLabelValues(rule, strconv.FormatBool(dryRun)).Inc()
+	}
+}
+
+// countSkipped increments the ignored counter, and the skipped counter labelled with the reason
+func countSkipped(reason string) {
+	ignoredCSRs.Inc()
+	skippedCSRs.WithLabelValues(reason).Inc()
+}
+
+// SetBuildInfo sets the build info gauge of the running binary
+func SetBuildInfo(commit, ref, version string) {
+	buildInfo.Reset()
+	buildInfo.WithLabelValues(commit, ref, version).Set(1)
+}
+
+// recordLastDecision sets the last decision timestamp of the CSR node to now. the node label
+// is left empty when PerNodeMetrics is disabled, to bound the cardinality on large clusters
+func (r *CertificateSigningRequestReconciler) recordLastDecision(csr *certificatesv1.CertificateSigningRequest, valid bool) {
+	node := ""
+	if r.PerNodeMetrics {
+		node = nodeNameOf(csr)
+	}
+
+	decision := "denied"
+	if valid {
+		decision = "approved"
+	}
+
+	lastDecisionTimestamp.WithLabelValues(node, decision).SetToCurrentTime()
+}
+
+//nolint:gochecknoinits // registering the collectors with the controller-runtime registry
+func init() {
+	metrics.Registry.MustRegister(approvedCSRs, deniedCSRs, ignoredCSRs, skippedCSRs, retriesExhausted, dnsFailures,
+		shadowRegexMismatches, shadowDNSMismatches, collectedCSRs, approvalRateLimitSaturation, pendingCSRsGauge,
+		lastDecisionTimestamp, buildInfo, reconcileDuration)
+}
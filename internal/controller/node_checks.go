@@ -0,0 +1,401 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"inet.af/netaddr"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+// getNode retrieves the Node object corresponding to the system:node:<nodename> CSR username
+func (r *CertificateSigningRequestReconciler) getNode(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) (*corev1.Node, error) {
+	nodeName := nodeNameOf(csr)
+
+	ctx, span := startSpan(ctx, "GetNode")
+	defer span.End()
+
+	var node corev1.Node
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: nodeName}, &node); err != nil {
+		return nil, err
+	}
+
+	return &node, nil
+}
+
+// NodeSelected returns true when no node label selector is configured, or when the Node
+// object requesting the certificate matches it. CSRs of non-matching nodes are left to
+// another controller.
+func (r *CertificateSigningRequestReconciler) NodeSelected(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) (bool, error) {
+	if r.NodeSelector == nil || r.NodeSelector.Empty() {
+		return true, nil
+	}
+
+	node, err := r.getNode(ctx, csr)
+	if err != nil {
+		return false, err
+	}
+
+	return r.NodeSelector.Matches(labels.Set(node.Labels)), nil
+}
+
+// priorityRequeueDivisor shortens the PendingRequeueInterval of the CSRs of the priority nodes
+const priorityRequeueDivisor = 4
+
+// NodePrioritized returns true when the Node object requesting the certificate matches the
+// priority node label selector. the CSRs of these nodes (e.g. control-plane nodes) bypass the
+// approvals rate limit, and are processed again sooner when left Pending. a Node object which
+// can't be retrieved isn't prioritized.
+func (r *CertificateSigningRequestReconciler) NodePrioritized(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) bool {
+	if r.PriorityNodeSelector == nil || r.PriorityNodeSelector.Empty() {
+		return false
+	}
+
+	node, err := r.getNode(ctx, csr)
+	if err != nil {
+		return false
+	}
+
+	return r.PriorityNodeSelector.Matches(labels.Set(node.Labels))
+}
+
+// annotatedDNSNames returns the comma separated DNS names recorded in the DNSNameNodeAnnotation
+// of the Node object requesting the certificate, normalized. a missing Node object or annotation
+// returns no names, i.e. the DNS names must be prefixed by the node name. the annotated names
+// are still checked against the provider regex, the kubelets being able to annotate their Node.
+func (r *CertificateSigningRequestReconciler) annotatedDNSNames(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest) (map[string]struct{}, error) {
+	if r.DNSNameNodeAnnotation == "" {
+		return nil, nil
+	}
+
+	node, err := r.getNode(ctx, csr)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{})
+
+	for _, name := range strings.Split(node.Annotations[r.DNSNameNodeAnnotation], ",") {
+		if name = NormalizeHostname(strings.TrimSpace(name)); name != "" {
+			names[name] = struct{}{}
+		}
+	}
+
+	return names, nil
+}
+
+// NodeIPCheck verifies that all the x509cr SAN IP Addresses are part of
+// the addresses listed in the status of the Node object requesting the certificate, or
+// of the addresses of its NodeAddressAnnotation and LoadBalancerIPAnnotation.
+// A missing Node object leaves the CSR Pending, for it to be processed again after the
+// PendingRequeueInterval, until the NodeExistenceGracePeriod (if set) following the CSR creation elapsed.
+func (r *CertificateSigningRequestReconciler) NodeIPCheck(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
+	if !r.VerifyNodeIPAddresses || len(x509cr.IPAddresses) == 0 {
+		return true, "", nil
+	}
+
+	node, err := r.getNode(ctx, csr)
+	if apierrors.IsNotFound(err) && r.nodeGracePeriodElapsed(csr) {
+		return false, r.missingNodeReason(), nil
+	} else if apierrors.IsNotFound(err) {
+		reason = "The Node object of the CSR requestor doesn't exist yet"
+		return false, reason, &pendingError{reason}
+	} else if err != nil {
+		return false, "Unable to retrieve the Node object of the CSR requestor", err
+	}
+
+	// the load balancer IPs, unlike the NodeAddressAnnotation ones, must still be part of the provider IP prefixes
+	annotatedIPs := annotatedIPAddresses(node, nil, r.NodeAddressAnnotation, r.LoadBalancerIPAnnotation)
+
+	for _, sanIP := range x509cr.IPAddresses {
+		if ipa, ok := netaddr.FromStdIP(sanIP); ok && annotatedIPs.Contains(ipa) {
+			continue
+		}
+
+		if !nodeHasIPAddress(node, sanIP, r.AllowZonedIPv6) {
+			return false, fmt.Sprintf("The SAN IP address %s is not one of the addresses of the Node %s", sanIP, node.Name), nil
+		}
+	}
+
+	return true, "", nil
+}
+
+// NodeDNSCheck verifies that all the x509cr SAN DNS Names are either one of the
+// Hostname addresses listed in the status of the Node object requesting the
+// certificate, or the name of this Node object.
+// A missing Node object leaves the CSR Pending, for it to be processed again after the
+// PendingRequeueInterval, until the NodeExistenceGracePeriod (if set) following the CSR creation elapsed.
+func (r *CertificateSigningRequestReconciler) NodeDNSCheck(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
+	if !r.VerifyNodeDNSNames || len(x509cr.DNSNames) == 0 {
+		return true, "", nil
+	}
+
+	node, err := r.getNode(ctx, csr)
+	if apierrors.IsNotFound(err) && r.nodeGracePeriodElapsed(csr) {
+		return false, r.missingNodeReason(), nil
+	} else if apierrors.IsNotFound(err) {
+		reason = "The Node object of the CSR requestor doesn't exist yet"
+		return false, reason, &pendingError{reason}
+	} else if err != nil {
+		return false, "Unable to retrieve the Node object of the CSR requestor", err
+	}
+
+	for _, sanDNSName := range x509cr.DNSNames {
+		if !nodeHasHostname(node, trimWildcard(sanDNSName)) {
+			return false, fmt.Sprintf("The SAN DNS Name %s is neither a hostname nor the name of the Node %s", sanDNSName, node.Name), nil
+		}
+	}
+
+	return true, "", nil
+}
+
+// cloudProviderIDPrefixes are the spec.providerID schemes InstanceIDFromProviderID can parse
+//
+//nolint:gochecknoglobals // read-only list of the supported cloud providers
+var cloudProviderIDPrefixes = []string{"aws://", "gce://", "azure://"}
+
+// InstanceIDFromProviderID extracts the instance portion of a Node spec.providerID, i.e. its last
+// path segment: the instance ID of aws:///<zone>/<instance-id>, the instance name of
+// gce://<project>/<zone>/<instance-name> and the VM name of azure:///subscriptions/.../virtualMachines/<vm-name>
+func InstanceIDFromProviderID(providerID string) (string, error) {
+	for _, prefix := range cloudProviderIDPrefixes {
+		if !strings.HasPrefix(providerID, prefix) {
+			continue
+		}
+
+		segments := strings.Split(strings.TrimRight(strings.TrimPrefix(providerID, prefix), "/"), "/")
+		if instanceID := segments[len(segments)-1]; instanceID != "" {
+			return instanceID, nil
+		}
+
+		return "", fmt.Errorf("the providerID %s has no instance portion", providerID)
+	}
+
+	return "", fmt.Errorf("the providerID %s is not of one of the supported formats %v", providerID, cloudProviderIDPrefixes)
+}
+
+// NodeInstanceIDCheck verifies that one of the x509cr SAN DNS names or URIs matches the cloud-provider
+// instance ID of the Node object requesting the certificate, parsed from its spec.providerID. a DNS name
+// matches when it, or its first label, equals the instance ID, and a URI when its host or its last path
+// segment does. A missing Node object is handled like in NodeIPCheck.
+func (r *CertificateSigningRequestReconciler) NodeInstanceIDCheck(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
+	if !r.VerifyCloudInstanceID {
+		return true, "", nil
+	}
+
+	node, err := r.getNode(ctx, csr)
+	if apierrors.IsNotFound(err) && r.nodeGracePeriodElapsed(csr) {
+		return false, r.missingNodeReason(), nil
+	} else if apierrors.IsNotFound(err) {
+		reason = "The Node object of the CSR requestor doesn't exist yet"
+		return false, reason, &pendingError{reason}
+	} else if err != nil {
+		return false, "Unable to retrieve the Node object of the CSR requestor", err
+	}
+
+	if node.Spec.ProviderID == "" {
+		// the cloud controller manager sets the providerID once it initialized the Node
+		reason = fmt.Sprintf("The Node %s has no spec.providerID yet", node.Name)
+		return false, reason, &pendingError{reason}
+	}
+
+	instanceID, err := InstanceIDFromProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return false, fmt.Sprintf("Unable to parse the spec.providerID of the Node %s: %v", node.Name, err), nil
+	}
+
+	for _, sanDNSName := range x509cr.DNSNames {
+		sanDNSName = NormalizeHostname(sanDNSName)
+		if sanDNSName == strings.ToLower(instanceID) || strings.SplitN(sanDNSName, ".", 2)[0] == strings.ToLower(instanceID) {
+			return true, "", nil
+		}
+	}
+
+	for _, uri := range x509cr.URIs {
+		if uri.Host == instanceID || path.Base(uri.Path) == instanceID {
+			return true, "", nil
+		}
+	}
+
+	return false, fmt.Sprintf("None of the SAN DNS names and URIs matches the instance %s of the Node %s", instanceID, node.Name), nil
+}
+
+// NodeReadyCheck verifies that the Node object requesting the certificate exists
+// and has a Ready condition set to True. A CSR whose Node doesn't exist is denied (once the
+// NodeExistenceGracePeriod, if set, elapsed), while a CSR whose Node isn't Ready yet is left
+// Pending, to be processed again after the PendingRequeueInterval.
+func (r *CertificateSigningRequestReconciler) NodeReadyCheck(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest) (valid bool, reason string, err error) {
+	if !r.RequireNodeReady {
+		return true, "", nil
+	}
+
+	node, err := r.getNode(ctx, csr)
+	if apierrors.IsNotFound(err) && (r.NodeExistenceGracePeriod == 0 || r.nodeGracePeriodElapsed(csr)) {
+		return false, r.missingNodeReason(), nil
+	} else if apierrors.IsNotFound(err) {
+		reason = "The Node object of the CSR requestor doesn't exist yet"
+		return false, reason, &pendingError{reason}
+	} else if err != nil {
+		return false, "Unable to retrieve the Node object of the CSR requestor", err
+	}
+
+	if !nodeIsReady(node) {
+		reason = fmt.Sprintf("The Node %s is not Ready yet", node.Name)
+		return false, reason, &pendingError{reason}
+	}
+
+	return true, "", nil
+}
+
+// isRenewal returns true when RelaxedRenewalMode is set and the Node object requesting the
+// certificate exists and is Ready, i.e. when the CSR most probably renews the serving certificate
+// of a node which is already part of the cluster. a Node object which can't be retrieved isn't renewing.
+func (r *CertificateSigningRequestReconciler) isRenewal(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) bool {
+	if !r.RelaxedRenewalMode {
+		return false
+	}
+
+	node, err := r.getNode(ctx, csr)
+
+	return err == nil && nodeIsReady(node)
+}
+
+// nodeAllowedIPs returns the set of the IP addresses allowed for the Node object requesting the
+// certificate on top of the provider IP prefixes: its PodCIDRs when AllowPodCIDRIPs is set, and the
+// addresses of its NodeAddressAnnotation which are part of the NodeAddressIPSet. the kubelets being
+// able to annotate their Node, the annotated addresses are never allowed without NodeAddressIPSet.
+// the set is empty when the Node object doesn't exist
+func (r *CertificateSigningRequestReconciler) nodeAllowedIPs(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest) (*netaddr.IPSet, error) {
+	var setBuilder netaddr.IPSetBuilder
+
+	node, err := r.getNode(ctx, csr)
+	if apierrors.IsNotFound(err) {
+		return setBuilder.IPSet()
+	} else if err != nil {
+		return nil, err
+	}
+
+	if nodeAddressIPSet := r.nodeAddressIPSet(); nodeAddressIPSet != nil {
+		setBuilder.AddSet(annotatedIPAddresses(node, nodeAddressIPSet, r.NodeAddressAnnotation))
+	}
+
+	if !r.AllowPodCIDRIPs {
+		return setBuilder.IPSet()
+	}
+
+	podCIDRs := node.Spec.PodCIDRs
+	if len(podCIDRs) == 0 && node.Spec.PodCIDR != "" {
+		podCIDRs = []string{node.Spec.PodCIDR}
+	}
+
+	for _, podCIDR := range podCIDRs {
+		prefix, err := netaddr.ParseIPPrefix(podCIDR)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the podCIDR %s of the Node %s: %w", podCIDR, node.Name, err)
+		}
+
+		setBuilder.AddPrefix(prefix)
+	}
+
+	return setBuilder.IPSet()
+}
+
+// annotatedIPAddresses returns the set of the comma separated IP addresses recorded in the given
+// annotations of the Node, e.g. its externally-reachable addresses behind a NAT in the
+// NodeAddressAnnotation. the empty annotation keys, the entries which aren't IP addresses and,
+// when within is not nil, the addresses which aren't part of it are skipped
+func annotatedIPAddresses(node *corev1.Node, within *netaddr.IPSet, annotations ...string) *netaddr.IPSet {
+	var setBuilder netaddr.IPSetBuilder
+
+	for _, annotation := range annotations {
+		if annotation == "" {
+			continue
+		}
+
+		for _, address := range strings.Split(node.Annotations[annotation], ",") {
+			if ip, err := netaddr.ParseIP(strings.TrimSpace(address)); err == nil && (within == nil || within.Contains(ip)) {
+				setBuilder.Add(ip)
+			}
+		}
+	}
+
+	ipSet, _ := setBuilder.IPSet()
+
+	return ipSet
+}
+
+// nodeGracePeriodElapsed returns true when a NodeExistenceGracePeriod is set, and
+// more time than it elapsed since the CSR creation
+func (r *CertificateSigningRequestReconciler) nodeGracePeriodElapsed(csr *certificatesv1.CertificateSigningRequest) bool {
+	return r.NodeExistenceGracePeriod > 0 && time.Since(csr.CreationTimestamp.Time) > r.NodeExistenceGracePeriod
+}
+
+func (r *CertificateSigningRequestReconciler) missingNodeReason() string {
+	if r.NodeExistenceGracePeriod > 0 {
+		return fmt.Sprintf("The Node object of the CSR requestor still doesn't exist %s after the CSR creation", r.NodeExistenceGracePeriod)
+	}
+
+	return "The Node object of the CSR requestor doesn't exist"
+}
+
+func nodeIsReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// nodeHasHostname returns true when hostname is the name or one of the hostnames of the Node,
+// compared once normalized
+func nodeHasHostname(node *corev1.Node, hostname string) bool {
+	if hostname = NormalizeHostname(hostname); hostname == NormalizeHostname(node.Name) {
+		return true
+	}
+
+	for _, a := range node.Status.Addresses {
+		if a.Type == corev1.NodeHostName && NormalizeHostname(a.Address) == hostname {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nodeHasIPAddress returns true when ip is one of the Node addresses. the zoned IPv6 addresses
+// (e.g. fe80::1%eth0) are compared without their zone when allowZoned is set, and never match otherwise
+func nodeHasIPAddress(node *corev1.Node, ip net.IP, allowZoned bool) bool {
+	for _, a := range node.Status.Addresses {
+		address := a.Address
+		if i := strings.IndexByte(address, '%'); i >= 0 && allowZoned {
+			address = address[:i]
+		}
+
+		if ip.Equal(net.ParseIP(address)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,585 @@
+package controller_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/foxcpp/go-mockdns"
+	"github.com/stretchr/testify/require"
+	"github.com/thanhpk/randstr"
+	"github.com/tj/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestNodeIPAddressesMatch(t *testing.T) {
+	nodeName := "node-ip-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.50")}
+	registerDNSZone(nodeName, ipAddresses)
+	createNode(t, nodeName, nil, corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "192.168.14.50"},
+	}})
+
+	csrController.VerifyNodeIPAddresses = true
+	defer func() { csrController.VerifyNodeIPAddresses = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeIPAddressesMismatch(t *testing.T) {
+	nodeName := "node-ip-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.51")}
+	registerDNSZone(nodeName, ipAddresses)
+	createNode(t, nodeName, nil, corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "192.168.14.52"},
+	}})
+
+	csrController.VerifyNodeIPAddresses = true
+	defer func() { csrController.VerifyNodeIPAddresses = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestNodeIPAddressesMissingNode(t *testing.T) {
+	nodeName := "node-ip-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.53")}
+	registerDNSZone(nodeName, ipAddresses)
+
+	csrController.VerifyNodeIPAddresses = true
+	defer func() { csrController.VerifyNodeIPAddresses = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeDNSNamesMatch(t *testing.T) {
+	nodeName := "node-dns-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.60")}
+	registerDNSZone(nodeName, ipAddresses)
+	createNode(t, nodeName, nil, corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+		{Type: corev1.NodeHostName, Address: nodeName + ".test.ch"},
+	}})
+
+	csrController.VerifyNodeDNSNames = true
+	defer func() { csrController.VerifyNodeDNSNames = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeDNSNamesMismatch(t *testing.T) {
+	nodeName := "node-dns-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.61")}
+	registerDNSZone(nodeName, ipAddresses)
+	createNode(t, nodeName, nil, corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+		{Type: corev1.NodeHostName, Address: nodeName + ".other.ch"},
+	}})
+
+	csrController.VerifyNodeDNSNames = true
+	defer func() { csrController.VerifyNodeDNSNames = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestNodeReady(t *testing.T) {
+	nodeName := "node-ready-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.70")}
+	registerDNSZone(nodeName, ipAddresses)
+	createNode(t, nodeName, nil, corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+		{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+	}})
+
+	csrController.RequireNodeReady = true
+	defer func() { csrController.RequireNodeReady = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeNotReady(t *testing.T) {
+	nodeName := "node-not-ready-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.71")}
+	registerDNSZone(nodeName, ipAddresses)
+	createNode(t, nodeName, nil, corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+		{Type: corev1.NodeReady, Status: corev1.ConditionFalse},
+	}})
+
+	csrController.RequireNodeReady = true
+	defer func() { csrController.RequireNodeReady = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeReadyMissingNode(t *testing.T) {
+	nodeName := "node-missing-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.72")}
+	registerDNSZone(nodeName, ipAddresses)
+
+	csrController.RequireNodeReady = true
+	defer func() { csrController.RequireNodeReady = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestNodeLabelSelectorMatch(t *testing.T) {
+	nodeName := "node-selector-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.73")}
+	registerDNSZone(nodeName, ipAddresses)
+	createNode(t, nodeName, map[string]string{"node-pool": "workers"}, corev1.NodeStatus{})
+
+	csrController.NodeSelector = labels.SelectorFromSet(labels.Set{"node-pool": "workers"})
+	defer func() { csrController.NodeSelector = nil }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeLabelSelectorMismatch(t *testing.T) {
+	nodeName := "node-selector-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.74")}
+	registerDNSZone(nodeName, ipAddresses)
+	createNode(t, nodeName, map[string]string{"node-pool": "control-plane"}, corev1.NodeStatus{})
+
+	csrController.NodeSelector = labels.SelectorFromSet(labels.Set{"node-pool": "workers"})
+	defer func() { csrController.NodeSelector = nil }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeExistenceGracePeriodElapsed(t *testing.T) {
+	nodeName := "node-grace-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.54")}
+	registerDNSZone(nodeName, ipAddresses)
+
+	csrController.VerifyNodeIPAddresses = true
+	csrController.NodeExistenceGracePeriod = time.Nanosecond
+	defer func() {
+		csrController.VerifyNodeIPAddresses = false
+		csrController.NodeExistenceGracePeriod = 0
+	}()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.False(t, approved)
+	assert.True(t, denied)
+}
+
+func TestDNSNameNodeAnnotation(t *testing.T) {
+	nodeName := "node-annotation-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	hostname := "override-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz") + ".test.ch"
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.55")}
+	// the SAN DNS name isn't prefixed by the node name, but complies with the provider regex
+	dnsResolver.Zones[hostname+"."] = mockdns.Zone{A: []string{"192.168.14.55"}}
+
+	node := createNode(t, nodeName, nil, corev1.NodeStatus{})
+	node.Annotations = map[string]string{"example.com/serving-hostnames": hostname}
+	require.Nil(t, k8sClient.Update(testContext, node), "Could not annotate the Node.")
+	require.Eventually(t, func() bool {
+		var cachedNode corev1.Node
+		err := csrController.Client.Get(testContext, types.NamespacedName{Name: nodeName}, &cachedNode)
+		return err == nil && cachedNode.Annotations["example.com/serving-hostnames"] == hostname
+	}, 2*time.Second, 50*time.Millisecond)
+
+	csrController.DNSNameNodeAnnotation = "example.com/serving-hostnames"
+	defer func() { csrController.DNSNameNodeAnnotation = "" }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     hostname,
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeAddressAnnotation(t *testing.T) {
+	nodeName := "node-address-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	// the NAT address is part of the provider IP prefixes, but not of the Node addresses
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.56"), net.ParseIP("192.168.200.56")}
+	registerDNSZone(nodeName, ipAddresses)
+
+	node := createNode(t, nodeName, nil, corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "192.168.14.56"},
+	}})
+	node.Annotations = map[string]string{"example.com/nat-addresses": "192.168.200.56, not-an-ip"}
+	require.Nil(t, k8sClient.Update(testContext, node), "Could not annotate the Node.")
+	require.Eventually(t, func() bool {
+		var cachedNode corev1.Node
+		err := csrController.Client.Get(testContext, types.NamespacedName{Name: nodeName}, &cachedNode)
+		return err == nil && cachedNode.Annotations["example.com/nat-addresses"] != ""
+	}, 2*time.Second, 50*time.Millisecond)
+
+	csrController.VerifyNodeIPAddresses = true
+	csrController.NodeAddressAnnotation = "example.com/nat-addresses"
+	defer func() {
+		csrController.VerifyNodeIPAddresses = false
+		csrController.NodeAddressAnnotation = ""
+	}()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestLoadBalancerIPAnnotation(t *testing.T) {
+	nodeName := "node-lb-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	// the load balancer IP is part of the provider IP prefixes, but not of the Node addresses
+	ipAddresses := []net.IP{net.ParseIP("192.168.14.57"), net.ParseIP("192.168.200.57")}
+	registerDNSZone(nodeName, ipAddresses)
+
+	node := createNode(t, nodeName, nil, corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "192.168.14.57"},
+	}})
+	node.Annotations = map[string]string{"example.com/load-balancer-ips": "192.168.200.57"}
+	require.Nil(t, k8sClient.Update(testContext, node), "Could not annotate the Node.")
+	require.Eventually(t, func() bool {
+		var cachedNode corev1.Node
+		err := csrController.Client.Get(testContext, types.NamespacedName{Name: nodeName}, &cachedNode)
+		return err == nil && cachedNode.Annotations["example.com/load-balancer-ips"] != ""
+	}, 2*time.Second, 50*time.Millisecond)
+
+	csrController.VerifyNodeIPAddresses = true
+	csrController.LoadBalancerIPAnnotation = "example.com/load-balancer-ips"
+	defer func() {
+		csrController.VerifyNodeIPAddresses = false
+		csrController.LoadBalancerIPAnnotation = ""
+	}()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: ipAddresses,
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestNodeAddressPrefixes(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "node-address-prefixes",
+		Annotations: map[string]string{"example.com/nat-addresses": "203.0.113.56,198.51.100.56"},
+	}}
+
+	testCases := []struct {
+		name                string
+		nodeAddressPrefixes string
+		ipAddress           string
+		allowed             bool
+	}{
+		{"provider prefixes", "", "192.168.14.56", true},
+		{"annotated without node address prefixes", "", "203.0.113.56", false},
+		{"annotated within the node address prefixes", "203.0.113.0/24", "203.0.113.56", true},
+		{"annotated outside of the node address prefixes", "203.0.113.0/24", "198.51.100.56", false},
+		{"within the node address prefixes but not annotated", "203.0.113.0/24", "203.0.113.57", false},
+	}
+
+	for _, tc := range testCases {
+		r := &controller.CertificateSigningRequestReconciler{
+			Client: fake.NewClientBuilder().WithObjects(node).Build(),
+			Config: controller.Config{
+				RegexStr:               `^[\w-]*\.test\.ch$`,
+				IPPrefixesStr:          "192.168.0.0/16",
+				AllowedIPAddresses:     1,
+				NodeAddressAnnotation:  "example.com/nat-addresses",
+				NodeAddressPrefixesStr: tc.nodeAddressPrefixes,
+			},
+		}
+		rules, err := controller.CompileProviderRules(&r.Config)
+		require.Nil(t, err, tc.name)
+		r.SetProviderRules(rules)
+
+		csr := createCsr(t, CsrParams{nodeName: node.Name, ipAddresses: []net.IP{net.ParseIP(tc.ipAddress)}})
+		x509cr, err := controller.ParseCSR(csr.Spec.Request)
+		require.Nil(t, err, tc.name)
+
+		valid, reason, err := r.WhitelistedIPCheck(context.Background(), &csr, x509cr)
+		require.Nil(t, err, tc.name)
+		assert.Equal(t, tc.allowed, valid, "%s: %s", tc.name, reason)
+	}
+}
+
+func TestNodePrioritized(t *testing.T) {
+	controlPlaneName := "node-priority-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	workerName := "node-priority-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	createNode(t, controlPlaneName, map[string]string{"node-role.kubernetes.io/control-plane": ""}, corev1.NodeStatus{})
+	createNode(t, workerName, nil, corev1.NodeStatus{})
+
+	selector, err := labels.Parse("node-role.kubernetes.io/control-plane")
+	require.Nil(t, err)
+
+	csrController.PriorityNodeSelector = selector
+	defer func() { csrController.PriorityNodeSelector = nil }()
+
+	controlPlaneCsr := createCsr(t, CsrParams{nodeName: controlPlaneName})
+	workerCsr := createCsr(t, CsrParams{nodeName: workerName})
+	missingNodeCsr := createCsr(t, CsrParams{})
+
+	assert.True(t, csrController.NodePrioritized(testContext, &controlPlaneCsr))
+	assert.False(t, csrController.NodePrioritized(testContext, &workerCsr))
+	assert.False(t, csrController.NodePrioritized(testContext, &missingNodeCsr))
+}
+
+func TestRelaxedRenewalModeSkipsDNSResolution(t *testing.T) {
+	// no DNS zone is registered, the DNS name of the Ready node doesn't resolve
+	nodeName := "node-renewal-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	createNode(t, nodeName, nil, corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+		{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+	}})
+
+	csrController.RelaxedRenewalMode = true
+	defer func() { csrController.RelaxedRenewalMode = false }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: []net.IP{net.ParseIP("192.168.14.90")},
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestInstanceIDFromProviderID(t *testing.T) {
+	for _, tc := range []struct{ providerID, instanceID string }{
+		{"aws:///eu-central-1a/i-0abc1234def567890", "i-0abc1234def567890"},
+		{"gce://my-project/europe-west6-a/node-1", "node-1"},
+		{"azure:///subscriptions/0000/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm-1", "vm-1"},
+	} {
+		instanceID, err := controller.InstanceIDFromProviderID(tc.providerID)
+		require.Nil(t, err, tc.providerID)
+		assert.Equal(t, tc.instanceID, instanceID)
+	}
+
+	for _, providerID := range []string{"", "kind://docker/kind/kind-worker", "aws:///"} {
+		_, err := controller.InstanceIDFromProviderID(providerID)
+		assert.NotNil(t, err, providerID)
+	}
+}
+
+func TestNodeDNSNamesNormalized(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node-normalized",
+			Annotations: map[string]string{"example.com/serving-hostnames": "Override-Normalized.Test.ch., other-node.special.ch"},
+		},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node-normalized.test.ch"},
+		}},
+	}
+
+	r := &controller.CertificateSigningRequestReconciler{
+		Client: fake.NewClientBuilder().WithObjects(node).Build(),
+		Config: controller.Config{
+			RegexStr:              `^[\w-]*\.test\.ch$`,
+			IPPrefixesStr:         "192.168.0.0/16",
+			AllowedDNSNames:       1,
+			DNSResolver:           staticResolver("192.168.14.93"),
+			VerifyNodeDNSNames:    true,
+			DNSNameNodeAnnotation: "example.com/serving-hostnames",
+		},
+	}
+	rules, err := controller.CompileProviderRules(&r.Config)
+	require.Nil(t, err)
+	r.SetProviderRules(rules)
+
+	// the SAN DNS name is the Node hostname, in mixed case and with a trailing dot
+	csr := createCsr(t, CsrParams{nodeName: "node-normalized", dnsName: "Node-Normalized.Test.ch."})
+	x509cr, err := controller.ParseCSR(csr.Spec.Request)
+	require.Nil(t, err)
+
+	valid, reason, err := r.NodeDNSCheck(context.Background(), &csr, x509cr)
+	require.Nil(t, err)
+	assert.True(t, valid, reason)
+
+	// the SAN DNS name is only allowed by the Node annotation, which is normalized as well
+	csr = createCsr(t, CsrParams{nodeName: "node-normalized", dnsName: "override-normalized.test.ch"})
+	x509cr, err = controller.ParseCSR(csr.Spec.Request)
+	require.Nil(t, err)
+
+	valid, reason, err = r.DNSCheck(context.Background(), &csr, x509cr)
+	require.Nil(t, err)
+	assert.True(t, valid, reason)
+
+	// the annotated names must still comply with the provider regex
+	csr = createCsr(t, CsrParams{nodeName: "node-normalized", dnsName: "other-node.special.ch"})
+	x509cr, err = controller.ParseCSR(csr.Spec.Request)
+	require.Nil(t, err)
+
+	valid, _, err = r.DNSCheck(context.Background(), &csr, x509cr)
+	require.Nil(t, err)
+	assert.False(t, valid)
+}
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+// ConfigOverride overrides some of the configuration values for the CSRs of the nodes matching its
+// node selector. it is parsed from one of the YAML values of the overrides ConfigMap, whose keys are
+// the flag names. the values left unset keep their global configuration.
+type ConfigOverride struct {
+	Name                 string          `json:"-"`
+	NodeSelectorStr      string          `json:"node-selector"`
+	NodeSelector         labels.Selector `json:"-"`
+	RegexStr             *string         `json:"provider-regex,omitempty"`
+	DNSRegexStr          *string         `json:"dns-regex,omitempty"`
+	IPPrefixesStr        *string         `json:"provider-ip-prefixes,omitempty"`
+	MaxExpirationSeconds *int32          `json:"max-expiration-sec,omitempty"`
+	AllowedDNSNames      *int            `json:"allowed-dns-names,omitempty"`
+	AllowedIPAddresses   *int            `json:"allowed-ip-addresses,omitempty"`
+	BypassDNSResolution  *bool           `json:"bypass-dns-resolution,omitempty"`
+	BypassHostnameCheck  *bool           `json:"bypass-hostname-check,omitempty"`
+}
+
+// ParseConfigOverrides parses the data of the overrides ConfigMap. the overrides are returned sorted
+// by key, which is the order in which they are matched against the node labels.
+func ParseConfigOverrides(data map[string]string) ([]ConfigOverride, error) {
+	overrides := make([]ConfigOverride, 0, len(data))
+
+	for name, value := range data {
+		override := ConfigOverride{Name: name}
+		if err := yaml.UnmarshalStrict([]byte(value), &override); err != nil {
+			return nil, fmt.Errorf("invalid override %s: %w", name, err)
+		}
+
+		if override.NodeSelectorStr == "" {
+			return nil, fmt.Errorf("invalid override %s: the node-selector must be set", name)
+		}
+
+		selector, err := labels.Parse(override.NodeSelectorStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid override %s: unable to parse the node-selector: %w", name, err)
+		}
+
+		override.NodeSelector = selector
+
+		for _, regexStr := range []*string{override.RegexStr, override.DNSRegexStr} {
+			if regexStr == nil {
+				continue
+			}
+
+			if _, err := regexp.Compile(*regexStr); err != nil {
+				return nil, fmt.Errorf("invalid override %s: unable to compile the regex %s", name, *regexStr)
+			}
+		}
+
+		overrides = append(overrides, override)
+	}
+
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Name < overrides[j].Name })
+
+	return overrides, nil
+}
+
+// apply sets the overridden values in config
+func (o *ConfigOverride) apply(config *Config) {
+	if o.RegexStr != nil {
+		config.RegexStr = *o.RegexStr
+		config.AdditionalRegexStrs = nil
+	}
+
+	if o.DNSRegexStr != nil {
+		config.DNSRegexStr = *o.DNSRegexStr
+	}
+
+	if o.IPPrefixesStr != nil {
+		// the family-specific prefixes would otherwise take precedence over the overridden ones
+		config.IPPrefixesStr = *o.IPPrefixesStr
+		config.IPv4PrefixesStr = ""
+		config.IPv6PrefixesStr = ""
+	}
+
+	if o.MaxExpirationSeconds != nil {
+		config.MaxExpirationSeconds = *o.MaxExpirationSeconds
+	}
+
+	if o.AllowedDNSNames != nil {
+		config.AllowedDNSNames = *o.AllowedDNSNames
+	}
+
+	if o.AllowedIPAddresses != nil {
+		config.AllowedIPAddresses = *o.AllowedIPAddresses
+	}
+
+	if o.BypassDNSResolution != nil {
+		config.BypassDNSResolution = *o.BypassDNSResolution
+	}
+
+	if o.BypassHostnameCheck != nil {
+		config.BypassHostnameCheck = *o.BypassHostnameCheck
+	}
+}
+
+// configOverridesCache keeps the parsed overrides of the last ConfigMap version retrieved, along
+// with the overridden configurations already validated and compiled, by override name. the latter
+// are dropped whenever the ConfigMap version or the provider rules they derive from change
+type configOverridesCache struct {
+	mu              sync.Mutex
+	resourceVersion string
+	overrides       []ConfigOverride
+	compiled        map[string]*compiledOverride
+}
+
+// compiledOverride is the outcome of the validation and the compilation of an overridden configuration
+type compiledOverride struct {
+	config Config
+	rules  ProviderRules
+	err    error
+}
+
+// resetCompiled drops the compiled overrides
+func (c *configOverridesCache) resetCompiled() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.compiled = nil
+}
+
+// loadConfigOverrides retrieves and parses the overrides ConfigMap. a missing ConfigMap has no overrides
+func (r *CertificateSigningRequestReconciler) loadConfigOverrides(ctx context.Context) ([]ConfigOverride, error) {
+	// the namespace/name format of the ConfigMap reference is checked by Config.Validate
+	namespacedName := strings.SplitN(r.OverridesConfigMap, "/", 2)
+	namespace, name := namespacedName[0], namespacedName[1]
+
+	if r.ClientSet == nil {
+		return nil, errNoClientSet
+	}
+
+	ctx, span := startSpan(ctx, "GetConfigOverrides")
+	defer span.End()
+
+	configMap, err := r.ClientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	r.overrides.mu.Lock()
+	defer r.overrides.mu.Unlock()
+
+	if configMap.ResourceVersion != r.overrides.resourceVersion {
+		overrides, err := ParseConfigOverrides(configMap.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		r.overrides.resourceVersion = configMap.ResourceVersion
+		r.overrides.overrides = overrides
+		r.overrides.compiled = nil
+	}
+
+	return r.overrides.overrides, nil
+}
+
+// overriddenReconciler returns a reconciler running the checks with the configuration overridden for the
+// Node of the CSR, by the first override whose node selector matches the Node labels. r is returned as is
+// when no override applies, including when the Node object doesn't exist.
+func (r *CertificateSigningRequestReconciler) overriddenReconciler(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest) (*CertificateSigningRequestReconciler, error) {
+	if r.OverridesConfigMap == "" {
+		return r, nil
+	}
+
+	overrides, err := r.loadConfigOverrides(ctx)
+	if err != nil || len(overrides) == 0 {
+		return r, err
+	}
+
+	node, err := r.getNode(ctx, csr)
+	if apierrors.IsNotFound(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for i := range overrides {
+		if overrides[i].NodeSelector.Matches(labels.Set(node.Labels)) {
+			return r.withOverride(&overrides[i])
+		}
+	}
+
+	return r, nil
+}
+
+// withOverride returns a copy of the reconciler, whose configuration is overridden. the overridden
+// configuration is validated and compiled once per version of the ConfigMap and of the provider rules
+func (r *CertificateSigningRequestReconciler) withOverride(override *ConfigOverride) (*CertificateSigningRequestReconciler, error) {
+	compiled := r.compileOverride(override)
+	if compiled.err != nil {
+		return nil, compiled.err
+	}
+
+	overridden := &CertificateSigningRequestReconciler{
+		ClientSet:     r.ClientSet,
+		Client:        r.Client,
+		Scheme:        r.Scheme,
+		EventRecorder: r.EventRecorder,
+		Config:        compiled.config,
+	}
+	overridden.SetProviderRules(compiled.rules)
+
+	return overridden, nil
+}
+
+// compileOverride returns the cached compiled override, or validates and compiles it
+func (r *CertificateSigningRequestReconciler) compileOverride(override *ConfigOverride) *compiledOverride {
+	r.overrides.mu.Lock()
+	defer r.overrides.mu.Unlock()
+
+	if compiled, ok := r.overrides.compiled[override.Name]; ok {
+		return compiled
+	}
+
+	r.rulesMu.RLock()
+	compiled := &compiledOverride{config: r.Config}
+	r.rulesMu.RUnlock()
+
+	override.apply(&compiled.config)
+
+	if err := compiled.config.Validate(); err != nil {
+		compiled.err = fmt.Errorf("the override %s results in an %w", override.Name, err)
+	} else if compiled.rules, err = CompileProviderRules(&compiled.config); err != nil {
+		compiled.err = fmt.Errorf("the override %s results in invalid provider rules: %w", override.Name, err)
+	}
+
+	if r.overrides.compiled == nil {
+		r.overrides.compiled = make(map[string]*compiledOverride)
+	}
+
+	r.overrides.compiled[override.Name] = compiled
+
+	return compiled
+}
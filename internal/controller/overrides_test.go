@@ -0,0 +1,117 @@
+package controller_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thanhpk/randstr"
+	"github.com/tj/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestParseConfigOverrides(t *testing.T) {
+	overrides, err := controller.ParseConfigOverrides(map[string]string{
+		"b-gpu":     "node-selector: node-pool=gpu\nmax-expiration-sec: 86400\n",
+		"a-workers": "node-selector: node-pool in (workers, batch)\nprovider-regex: ^worker-\\w+\\.test\\.ch$\n",
+	})
+	require.Nil(t, err)
+	require.Len(t, overrides, 2)
+
+	// the overrides are sorted by key
+	assert.Equal(t, "a-workers", overrides[0].Name)
+	assert.True(t, overrides[0].NodeSelector.Matches(labels.Set{"node-pool": "batch"}))
+	require.NotNil(t, overrides[1].MaxExpirationSeconds)
+	assert.Equal(t, int32(86400), *overrides[1].MaxExpirationSeconds)
+	assert.Nil(t, overrides[1].RegexStr)
+
+	for _, invalid := range []string{
+		"max-expiration-sec: 86400",                // no node selector
+		"node-selector: node-pool=gpu\nunknown: 1", // unknown key
+		"node-selector: node-pool=gpu\nprovider-regex: ^[a-z",
+		"node-selector: '!!'",
+	} {
+		_, err := controller.ParseConfigOverrides(map[string]string{"invalid": invalid})
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestConfigOverridesApplied(t *testing.T) {
+	// no DNS zone is registered, the DNS resolution must be bypassed by the override
+	nodeName := "node-override-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	createNode(t, nodeName, map[string]string{"node-pool": "overridden"}, corev1.NodeStatus{})
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "csr-approver-overrides", Namespace: "default"},
+		Data:       map[string]string{"overridden": "node-selector: node-pool=overridden\nbypass-dns-resolution: true\n"},
+	}
+	require.Nil(t, k8sClient.Create(testContext, configMap))
+	defer func() { _ = k8sClient.Delete(testContext, configMap) }()
+
+	csrController.OverridesConfigMap = "default/csr-approver-overrides"
+	defer func() { csrController.OverridesConfigMap = "" }()
+
+	csr := createCsr(t, CsrParams{
+		nodeName:    nodeName,
+		dnsName:     nodeName + ".test.ch",
+		ipAddresses: []net.IP{net.ParseIP("192.168.14.91")},
+	})
+	_, nodeClientSet, _ := createControlPlaneUser(t, csr.Spec.Username, []string{"system:masters"})
+	_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+	require.Nil(t, err, "Could not create the CSR.")
+
+	approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+	t.Log(reason)
+	require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+	assert.True(t, approved)
+	assert.False(t, denied)
+}
+
+func TestConfigOverridesRecompiledOnChange(t *testing.T) {
+	nodeName := "node-override-" + randstr.String(4, "0123456789abcdefghijklmnopqrstuvwxyz")
+	createNode(t, nodeName, map[string]string{"node-pool": "recompiled"}, corev1.NodeStatus{})
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "csr-approver-recompiled-overrides", Namespace: "default"},
+		Data:       map[string]string{"recompiled": "node-selector: node-pool=recompiled\nbypass-dns-resolution: true\n"},
+	}
+	require.Nil(t, k8sClient.Create(testContext, configMap))
+	defer func() { _ = k8sClient.Delete(testContext, configMap) }()
+
+	csrController.OverridesConfigMap = "default/csr-approver-recompiled-overrides"
+	defer func() { csrController.OverridesConfigMap = "" }()
+
+	_, nodeClientSet, _ := createControlPlaneUser(t, "system:node:"+nodeName, []string{"system:masters"})
+
+	for _, approval := range []struct {
+		providerRegex string
+		approved      bool
+	}{
+		{"", true},
+		// the override compiled for the previous ConfigMap version mustn't be reused
+		{`^other-\w+\.test\.ch$`, false},
+	} {
+		if approval.providerRegex != "" {
+			configMap.Data["recompiled"] += "provider-regex: " + approval.providerRegex + "\n"
+			require.Nil(t, k8sClient.Update(testContext, configMap))
+		}
+
+		csr := createCsr(t, CsrParams{
+			nodeName:    nodeName,
+			dnsName:     nodeName + ".test.ch",
+			ipAddresses: []net.IP{net.ParseIP("192.168.14.92")},
+		})
+		_, err := nodeClientSet.CertificatesV1().CertificateSigningRequests().Create(testContext, &csr, metav1.CreateOptions{})
+		require.Nil(t, err, "Could not create the CSR.")
+
+		approved, denied, reason, err := waitCsrApprovalStatus(csr.Name)
+		t.Log(reason)
+		require.Nil(t, err, "Could not retrieve the CSR to check its approval status")
+		assert.Equal(t, approval.approved, approved)
+		assert.Equal(t, !approval.approved, denied)
+	}
+}
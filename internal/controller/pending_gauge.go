@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// PendingCSRsGauge is a manager Runnable refreshing the pending CSRs gauge every Interval,
+// from the CSRs cached by the controller
+type PendingCSRsGauge struct {
+	Reconciler *CertificateSigningRequestReconciler
+	Interval   time.Duration
+	Log        logr.Logger
+}
+
+// NeedLeaderElection returns false, for every replica to expose the backlog, even when
+// the leader is stalled
+func (g *PendingCSRsGauge) NeedLeaderElection() bool {
+	return false
+}
+
+// Start refreshes the gauge until ctx is done
+func (g *PendingCSRsGauge) Start(ctx context.Context) error {
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+
+	for {
+		pendingCSRs, err := g.Reconciler.listPendingCSRs(ctx)
+		if err != nil {
+			g.Log.Error(err, "unable to list the Pending CSRs")
+		} else {
+			pendingCSRsGauge.Set(float64(len(pendingCSRs)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"inet.af/netaddr"
+)
+
+// ProviderRules are the compiled provider regexes and IP sets, along with the
+// strings they were compiled from. they can be swapped while the controller
+// runs, e.g. when its config file changes
+type ProviderRules struct {
+	RegexStr            string
+	AdditionalRegexStrs []string
+	DNSRegexStr         string
+	ShadowRegexStr      string
+	IPPrefixesStr       string
+	IPv4PrefixesStr     string
+	IPv6PrefixesStr     string
+	DeniedIPPrefixesStr string
+	// NodeAddressPrefixesStr limits the addresses of the NodeAddressAnnotation
+	NodeAddressPrefixesStr string
+
+	Regexps      []func(string) bool
+	DNSRegexp    func(string) bool
+	ShadowRegexp func(string) bool
+	IPSet        *netaddr.IPSet
+	IPv4Set      *netaddr.IPSet
+	IPv6Set      *netaddr.IPSet
+	// DeniedIPSet is nil unless denied IP prefixes are configured
+	DeniedIPSet *netaddr.IPSet
+	// NodeAddressIPSet is nil unless node address prefixes are configured
+	NodeAddressIPSet *netaddr.IPSet
+}
+
+// CompileProviderRules compiles the provider regexes and builds the sets of allowed IP addresses,
+// out of which the denied IP prefixes are carved
+func CompileProviderRules(config *Config) (rules ProviderRules, err error) {
+	if config.RegexStr == "" && len(config.AllowedDNSSuffixes) == 0 {
+		return rules, fmt.Errorf("the provider-spefic regex must be specified")
+	}
+
+	regexStrs := config.AdditionalRegexStrs
+	if config.RegexStr != "" {
+		// an empty provider regex, when the allowed DNS suffixes are set, would match any DNS name
+		regexStrs = append([]string{config.RegexStr}, regexStrs...)
+	}
+
+	for _, regexStr := range regexStrs {
+		providerRegexp, err := regexp.Compile(regexStr)
+		if err != nil {
+			return rules, fmt.Errorf("unable to compile the provider regex: %s", regexStr)
+		}
+
+		rules.Regexps = append(rules.Regexps, providerRegexp.MatchString)
+	}
+
+	if config.DNSRegexStr != "" {
+		dnsRegexp, err := regexp.Compile(config.DNSRegexStr)
+		if err != nil {
+			return rules, fmt.Errorf("unable to compile the DNS regex: %s", config.DNSRegexStr)
+		}
+
+		rules.DNSRegexp = dnsRegexp.MatchString
+	}
+
+	if config.ShadowRegexStr != "" {
+		shadowRegexp, err := regexp.Compile(config.ShadowRegexStr)
+		if err != nil {
+			return rules, fmt.Errorf("unable to compile the shadow provider regex: %s", config.ShadowRegexStr)
+		}
+
+		rules.ShadowRegexp = shadowRegexp.MatchString
+	}
+
+	rules.RegexStr = config.RegexStr
+	rules.AdditionalRegexStrs = config.AdditionalRegexStrs
+	rules.DNSRegexStr = config.DNSRegexStr
+	rules.ShadowRegexStr = config.ShadowRegexStr
+	rules.IPPrefixesStr = config.IPPrefixesStr
+	rules.IPv4PrefixesStr = config.IPv4PrefixesStr
+	rules.IPv6PrefixesStr = config.IPv6PrefixesStr
+	rules.DeniedIPPrefixesStr = config.DeniedIPPrefixesStr
+	rules.NodeAddressPrefixesStr = config.NodeAddressPrefixesStr
+
+	rules.IPSet, err = buildIPSet(config.IPPrefixesStr, nil)
+	if err != nil {
+		return rules, fmt.Errorf("unable to build the Set of valid IP addresses: %w", err)
+	}
+
+	if config.IPv4PrefixesStr != "" {
+		rules.IPv4Set, err = buildIPSet(config.IPv4PrefixesStr, netaddr.IP.Is4)
+		if err != nil {
+			return rules, fmt.Errorf("unable to build the Set of valid IPv4 addresses: %w", err)
+		}
+	}
+
+	if config.IPv6PrefixesStr != "" {
+		rules.IPv6Set, err = buildIPSet(config.IPv6PrefixesStr, netaddr.IP.Is6)
+		if err != nil {
+			return rules, fmt.Errorf("unable to build the Set of valid IPv6 addresses: %w", err)
+		}
+	}
+
+	if config.NodeAddressPrefixesStr != "" {
+		rules.NodeAddressIPSet, err = buildIPSet(config.NodeAddressPrefixesStr, nil)
+		if err != nil {
+			return rules, fmt.Errorf("unable to build the Set of the allowed node annotation IP addresses: %w", err)
+		}
+	}
+
+	if config.DeniedIPPrefixesStr != "" {
+		deniedIPSet, err := buildIPSet(config.DeniedIPPrefixesStr, nil)
+		if err != nil {
+			return rules, fmt.Errorf("unable to build the Set of denied IP addresses: %w", err)
+		}
+
+		rules.DeniedIPSet = deniedIPSet
+
+		for _, ipSet := range []**netaddr.IPSet{&rules.IPSet, &rules.IPv4Set, &rules.IPv6Set} {
+			if *ipSet == nil {
+				continue
+			}
+
+			if *ipSet, err = subtractIPSet(*ipSet, deniedIPSet); err != nil {
+				return rules, fmt.Errorf("unable to remove the denied IP addresses from the valid ones: %w", err)
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// subtractIPSet returns the IP addresses of ipSet which aren't part of removed
+func subtractIPSet(ipSet, removed *netaddr.IPSet) (*netaddr.IPSet, error) {
+	var setBuilder netaddr.IPSetBuilder
+
+	setBuilder.AddSet(ipSet)
+	setBuilder.RemoveSet(removed)
+
+	return setBuilder.IPSet()
+}
+
+// buildIPSet parses the comma separated IP prefixes into an IPSet.
+// when inFamily is not nil, every prefix must belong to the corresponding address family
+func buildIPSet(ipPrefixesStr string, inFamily func(netaddr.IP) bool) (*netaddr.IPSet, error) {
+	var setBuilder netaddr.IPSetBuilder
+
+	for _, ipPrefix := range strings.Split(ipPrefixesStr, ",") {
+		ipPref, err := netaddr.ParseIPPrefix(ipPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse IP prefix %s: %w", ipPrefix, err)
+		}
+
+		if inFamily != nil && !inFamily(ipPref.IP()) {
+			return nil, fmt.Errorf("the IP prefix %s doesn't belong to the expected address family", ipPrefix)
+		}
+
+		setBuilder.AddPrefix(ipPref)
+	}
+
+	return setBuilder.IPSet()
+}
+
+// SetProviderRules atomically replaces the provider regexes and IP sets of the reconciler. the
+// configuration overrides, derived from them, are compiled again
+func (r *CertificateSigningRequestReconciler) SetProviderRules(rules ProviderRules) {
+	// not under rulesMu, which compileOverride acquires while holding the overrides cache lock
+	defer r.overrides.resetCompiled()
+
+	r.rulesMu.Lock()
+	defer r.rulesMu.Unlock()
+
+	r.RegexStr = rules.RegexStr
+	r.AdditionalRegexStrs = rules.AdditionalRegexStrs
+	r.DNSRegexStr = rules.DNSRegexStr
+	r.ShadowRegexStr = rules.ShadowRegexStr
+	r.IPPrefixesStr = rules.IPPrefixesStr
+	r.IPv4PrefixesStr = rules.IPv4PrefixesStr
+	r.IPv6PrefixesStr = rules.IPv6PrefixesStr
+	r.DeniedIPPrefixesStr = rules.DeniedIPPrefixesStr
+	r.NodeAddressPrefixesStr = rules.NodeAddressPrefixesStr
+
+	r.ProviderRegexps = rules.Regexps
+	r.DNSRegexp = rules.DNSRegexp
+	r.ShadowRegexp = rules.ShadowRegexp
+	r.ProviderIPSet = rules.IPSet
+	r.ProviderIPv4Set = rules.IPv4Set
+	r.ProviderIPv6Set = rules.IPv6Set
+	r.ProviderDeniedIPSet = rules.DeniedIPSet
+	r.NodeAddressIPSet = rules.NodeAddressIPSet
+}
+
+// ConfigHandler serves the effective configuration of the controller, including the
+// reloaded provider rules, as JSON. the compiled and sensitive fields are omitted.
+func (r *CertificateSigningRequestReconciler) ConfigHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.rulesMu.RLock()
+	effectiveConfig, err := json.Marshal(r.Config)
+	r.rulesMu.RUnlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(effectiveConfig)
+}
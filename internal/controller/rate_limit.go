@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// newApprovalLimiter returns a token bucket allowing maxPerMinute approvals per minute,
+// in bursts of up to maxPerMinute approvals. it returns nil when the approvals aren't rate-limited
+func newApprovalLimiter(maxPerMinute int) *rate.Limiter {
+	if maxPerMinute <= 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Every(time.Minute/time.Duration(maxPerMinute)), maxPerMinute)
+}
+
+// reserveApproval takes an approval token from the bucket, and returns its reservation for the
+// token to be given back with cancelApproval when the approval fails. when the bucket is empty, the
+// token isn't taken and the delay after which one becomes available is returned instead. the
+// reservation is nil when the approvals aren't rate-limited or the token wasn't taken
+func (r *CertificateSigningRequestReconciler) reserveApproval() (reservation *rate.Reservation, delay time.Duration) {
+	if r.approvalLimiter == nil {
+		return nil, 0
+	}
+
+	reservation = r.approvalLimiter.Reserve()
+
+	if delay = reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		reservation = nil
+	}
+
+	r.observeApprovalSaturation()
+
+	return reservation, delay
+}
+
+// cancelApproval gives the token of a failed approval back to the bucket, e.g. when the CSR
+// was updated in the meantime and is processed again
+func (r *CertificateSigningRequestReconciler) cancelApproval(reservation *rate.Reservation) {
+	if reservation == nil {
+		return
+	}
+
+	reservation.Cancel()
+	r.observeApprovalSaturation()
+}
+
+// observeApprovalSaturation sets the approvals rate limit saturation gauge, i.e. the share of the
+// bucket tokens which are taken
+func (r *CertificateSigningRequestReconciler) observeApprovalSaturation() {
+	saturation := 1 - r.approvalLimiter.Tokens()/float64(r.approvalLimiter.Burst())
+	if saturation > 1 {
+		saturation = 1
+	}
+
+	approvalRateLimitSaturation.Set(saturation)
+}
@@ -0,0 +1,15 @@
+package controller
+
+import (
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// APIServerCheck is a readiness checker failing when the API server can't be reached.
+// it performs a lightweight API call, listing at most one CSR.
+func (r *CertificateSigningRequestReconciler) APIServerCheck(req *http.Request) error {
+	_, err := r.ClientSet.CertificatesV1().CertificateSigningRequests().List(req.Context(), metav1.ListOptions{Limit: 1})
+
+	return err
+}
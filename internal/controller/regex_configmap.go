@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// parseConfigMapKeyRef splits a <namespace>/<name>/<key> ConfigMap key reference
+func parseConfigMapKeyRef(ref string) (namespace, name, key string, ok bool) {
+	parts := strings.SplitN(ref, "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", false
+	}
+
+	return parts[0], parts[1], parts[2], true
+}
+
+// ReadRegexConfigMap retrieves the provider regex from the ConfigMap key referenced by the
+// RegexConfigMapRef of config, along with the resource version of the ConfigMap. unlike the
+// overrides ConfigMap, a missing ConfigMap or key is an error
+func ReadRegexConfigMap(ctx context.Context, clientSet clientset.Interface,
+	config *Config) (regexStr, resourceVersion string, err error) {
+	namespace, name, key, ok := parseConfigMapKeyRef(config.RegexConfigMapRef)
+	if !ok {
+		return "", "", fmt.Errorf("the provider regex ConfigMap reference %q is not of the form <namespace>/<name>/<key>",
+			config.RegexConfigMapRef)
+	}
+
+	configMap, err := clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("unable to retrieve the provider regex ConfigMap %s/%s: %w", namespace, name, err)
+	}
+
+	regexStr, ok = configMap.Data[key]
+	if !ok {
+		return "", "", fmt.Errorf("the provider regex ConfigMap %s/%s has no key %s", namespace, name, key)
+	}
+
+	return strings.TrimSpace(regexStr), configMap.ResourceVersion, nil
+}
@@ -0,0 +1,32 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestReadRegexConfigMap(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "csr-policy", ResourceVersion: "42"},
+		Data:       map[string]string{"provider-regex": "^node-\\w+\\.example\\.com$\n"},
+	})
+
+	regexStr, resourceVersion, err := controller.ReadRegexConfigMap(context.Background(), clientSet,
+		&controller.Config{RegexConfigMapRef: "kube-system/csr-policy/provider-regex"})
+	require.Nil(t, err)
+	assert.Equal(t, `^node-\w+\.example\.com$`, regexStr)
+	assert.Equal(t, "42", resourceVersion)
+
+	for _, ref := range []string{"kube-system/csr-policy/missing-key", "kube-system/missing-configmap/provider-regex"} {
+		_, _, err = controller.ReadRegexConfigMap(context.Background(), clientSet, &controller.Config{RegexConfigMapRef: ref})
+		assert.NotNil(t, err, ref)
+	}
+}
@@ -3,60 +3,154 @@ package controller
 import (
 	"context"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
 	"inet.af/netaddr"
 
 	certificatesv1 "k8s.io/api/certificates/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // DNSCheck is a function checking that the DNS name:
-// complies with the provider-specific regex
-// is resolvable (this check can be opted out with a parameter)
+// complies with the provider-specific regex, or is recorded in the Node DNS name annotation
+// is resolvable (this check can be opted out with a parameter, and is skipped for the
+// renewals in RelaxedRenewalMode)
 //
 //nolint:gocyclo // see above
 func (r *CertificateSigningRequestReconciler) DNSCheck(ctx context.Context, csr *certificatesv1.CertificateSigningRequest, x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
-	if valid = (len(x509cr.DNSNames) <= r.AllowedDNSNames); !valid {
+	dnsNames := x509cr.DNSNames
+
+	if r.RequireFQDNAndShortname {
+		// the prescriptive short name + FQDN pair supersedes the number of allowed DNS names.
+		// the short name is derived from the FQDN, which is the only one checked further
+		var fqdn string
+		if fqdn, valid, reason = r.fqdnAndShortnameCheck(csr, x509cr); !valid {
+			return
+		}
+
+		dnsNames = []string{fqdn}
+	} else if valid = (len(x509cr.DNSNames) <= r.AllowedDNSNames); !valid {
 		reason = "The x509 Cert Request contains more DNS names than allowed through the config flag"
 		return
 	}
 
 	// no DNS name to check, the DNS check is approved
-	if len(x509cr.DNSNames) == 0 {
+	if len(dnsNames) == 0 {
 		valid = true
 		return valid, reason, nil
 	}
 
+	if valid, reason = r.wildcardCheck(x509cr); !valid {
+		return
+	}
+
 	// bypassing DNS reslution - DNS check is approved
 	if r.BypassDNSResolution {
 		valid = true
 		return valid, reason, nil
 	}
 
-	dnsCtx, dnsCtxCancel := context.WithDeadline(ctx, time.Now().Add(time.Second)) // 1 second timeout for the dns request
+	annotatedNames, err := r.annotatedDNSNames(ctx, csr)
+	if err != nil {
+		return false, "Unable to retrieve the Node object of the CSR requestor", err
+	}
+
+	_, regexSpan := startSpan(ctx, phaseRegex)
+	regexStart := time.Now()
+	valid, reason = r.regexCheck(csr, dnsNames, annotatedNames)
+	r.shadowRegexCheck(ctx, dnsNames)
+
+	observePhase(phaseRegex, regexStart)
+	regexSpan.End()
+
+	if !valid {
+		return
+	}
+
+	// the renewal CSRs of the nodes already part of the cluster skip the DNS resolution
+	if r.isRenewal(ctx, csr) {
+		log.FromContext(ctx).V(1).Info("Skipping the DNS resolution of the renewal CSR of a Ready node")
+		return true, "", nil
+	}
+
+	dnsTimeout := r.DNSResolutionTimeout
+	if dnsTimeout <= 0 {
+		dnsTimeout = DefaultDNSResolutionTimeout
+	}
+
+	dnsCtx, dnsCtxCancel := context.WithTimeout(ctx, dnsTimeout)
 	defer dnsCtxCancel()
 
-	var allResolvedAddrs []string
+	dnsCtx, dnsSpan := startSpan(dnsCtx, phaseDNS)
+	defer dnsSpan.End()
 
-	for _, sanDNSName := range x509cr.DNSNames {
-		hostname := strings.TrimPrefix(csr.Spec.Username, "system:node:")
+	defer observePhase(phaseDNS, time.Now())
 
-		if valid = strings.HasPrefix(sanDNSName, hostname); !valid && !r.BypassHostnameCheck {
-			reason = "The SAN DNS Name in the x509 CSR is not prefixed by the node name (hostname)"
-			return
+	if r.RequireAllDNSResolve {
+		// every SAN DNS name is resolved, including the short name of the FQDN and short name pair
+		dnsNames = x509cr.DNSNames
+	}
+
+	if r.UseReverseDNS {
+		if r.RequireResolvedIPInPrefix || r.RequireAllDNSResolve {
+			if _, valid, reason, err = r.forwardResolve(dnsCtx, dnsNames); !valid {
+				return
+			}
 		}
 
-		if valid = r.ProviderRegexp(sanDNSName); !valid {
-			reason = "The SAN DNS name in the x509 CR is not allowed by the Cloud provider regex"
-			return
+		return r.reverseDNSCheck(dnsCtx, x509cr)
+	}
+
+	resolvedIPSet, valid, reason, err := r.forwardResolve(dnsCtx, dnsNames)
+	if !valid {
+		return
+	}
+
+	sanIPAddrs := x509cr.IPAddresses
+	for _, ip := range sanIPAddrs {
+		ipa, ok := netaddr.FromStdIP(ip)
+		if !ok {
+			return false, fmt.Sprintf("Error while parsing x509 CR IP address %s, denying the CSR", ip), nil
+		}
+
+		if resolvedIPSet.Contains(ipa) {
+			continue
+		}
+
+		if valid, reason = r.dnsMismatch(dnsCtx, fmt.Sprintf("One of the SAN IP addresses, %s, "+
+			"is not contained in the set of resolved IP addresses, denying the CSR.", ipa)); !valid {
+			return valid, reason, nil
 		}
+	}
+
+	return valid, reason, nil
+}
+
+// forwardResolve resolves the SAN DNS names, and verifies that all the resolved IP addresses
+// are part of the provider-specified IP prefixes. the set of resolved IP addresses is returned
+func (r *CertificateSigningRequestReconciler) forwardResolve(dnsCtx context.Context,
+	dnsNames []string) (resolvedIPSet *netaddr.IPSet, valid bool, reason string, err error) {
+	var allResolvedAddrs []string
 
-		resolvedAddrs, err := r.DNSResolver.LookupHost(dnsCtx, sanDNSName)
+	for _, sanDNSName := range dnsNames {
+		resolvedAddrs, err := r.DNSResolver.LookupHost(dnsCtx, trimWildcard(sanDNSName))
+
+		var dnsErr *net.DNSError
+		if dnsCtx.Err() != nil || (errors.As(err, &dnsErr) && dnsErr.IsTimeout) {
+			// the resolution timed out or was cancelled: we can't tell whether the name is valid, the CSR must be processed again
+			countInterruptedLookup(dnsCtx)
+
+			return nil, false, fmt.Sprintf("The resolution of the SAN DNS Name %s %s", sanDNSName, interruptedLookup(dnsCtx)),
+				lookupError(dnsCtx, err)
+		}
 
 		if err != nil || len(resolvedAddrs) == 0 {
-			return false, "The SAN DNS Name could not be resolved, denying the CSR", nil
+			dnsFailures.WithLabelValues(lookupFailure(err)).Inc()
+			return nil, false, fmt.Sprintf("The SAN DNS Name %s could not be resolved, denying the CSR", sanDNSName), nil
 		}
 
 		allResolvedAddrs = append(allResolvedAddrs, resolvedAddrs...)
@@ -67,39 +161,298 @@ func (r *CertificateSigningRequestReconciler) DNSCheck(ctx context.Context, csr
 	for _, a := range allResolvedAddrs {
 		ipaddr, err := netaddr.ParseIP(a)
 		if err != nil {
-			return false, fmt.Sprintf("Error while parsing resolved IP address %s, denying the CSR", ipaddr), nil
+			dnsFailures.WithLabelValues(dnsFailureError).Inc()
+			return nil, false, fmt.Sprintf("Error while parsing resolved IP address %s, denying the CSR", a), nil
+		}
+
+		var ok bool
+		if ipaddr, ok = r.unzonedIP(ipaddr); !ok {
+			dnsFailures.WithLabelValues(dnsFailureMismatch).Inc()
+			return nil, false, fmt.Sprintf("The resolved IP address %s has an IPv6 zone, which isn't allowed. denying the certificate", a), nil
 		}
 
 		setBuilder.Add(ipaddr)
 
-		if !r.ProviderIPSet.Contains(ipaddr) {
-			return false, fmt.Sprintf("One of the resolved IP addresses, %s,"+
+		if !r.ipAllowed(ipaddr) {
+			dnsFailures.WithLabelValues(dnsFailureMismatch).Inc()
+
+			return nil, false, fmt.Sprintf("One of the resolved IP addresses, %s,"+
 				"isn't part of the provider-specified set of whitelisted IP. denying the certificate",
 				ipaddr), nil
 		}
 	}
 
-	resolvedIPSet, _ := setBuilder.IPSet()
+	resolvedIPSet, _ = setBuilder.IPSet()
 
-	sanIPAddrs := x509cr.IPAddresses
-	for _, ip := range sanIPAddrs {
-		ipa, ok := netaddr.FromStdIP(ip)
-		if !ok {
-			return false, fmt.Sprintf("Error while parsing x509 CR IP address %s, denying the CSR", ip), nil
+	return resolvedIPSet, true, "", nil
+}
+
+// interruptedLookup describes why a lookup didn't complete
+func interruptedLookup(dnsCtx context.Context) string {
+	if errors.Is(dnsCtx.Err(), context.Canceled) {
+		return "was cancelled"
+	}
+
+	return "timed out"
+}
+
+// countInterruptedLookup counts the timed out lookups. the cancelled ones, e.g. when the controller
+// is stopping, say nothing about the health of the DNS
+func countInterruptedLookup(dnsCtx context.Context) {
+	if !errors.Is(dnsCtx.Err(), context.Canceled) {
+		dnsFailures.WithLabelValues(dnsFailureTimeout).Inc()
+	}
+}
+
+// lookupFailure returns the DNS failure reason of a lookup which didn't return any address
+func lookupFailure(err error) string {
+	var dnsErr *net.DNSError
+	if err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return dnsFailureNXDomain
+	}
+
+	return dnsFailureError
+}
+
+// lookupError returns the error of an interrupted lookup, falling back to the context error
+// for the resolvers which don't report it: the CSR must not be denied when the error is nil
+func lookupError(dnsCtx context.Context, err error) error {
+	if err == nil {
+		return dnsCtx.Err()
+	}
+
+	return err
+}
+
+// unzonedIP strips the IPv6 zone of ip (e.g. fe80::1%eth0), for the address to be compared with
+// the (unzoned) SAN IP addresses and IP prefixes. ok is false for a zoned address unless AllowZonedIPv6 is set
+func (r *CertificateSigningRequestReconciler) unzonedIP(ip netaddr.IP) (unzoned netaddr.IP, ok bool) {
+	if ip.Zone() == "" {
+		return ip, true
+	}
+
+	return ip.WithZone(""), r.AllowZonedIPv6
+}
+
+// wildcardCheck denies the SAN DNS names containing a wildcard, unless AllowWildcardDNS is set,
+// in which case only a leading `*.` label is permitted
+func (r *CertificateSigningRequestReconciler) wildcardCheck(x509cr *x509.CertificateRequest) (valid bool, reason string) {
+	for _, sanDNSName := range x509cr.DNSNames {
+		if !strings.Contains(sanDNSName, "*") {
+			continue
 		}
 
-		if !resolvedIPSet.Contains(ipa) {
-			return false, fmt.Sprintf("One of the SAN IP addresses, %s, "+
-				"is not contained in the set of resolved IP addresses, denying the CSR.", ipa), nil
+		if !r.AllowWildcardDNS {
+			return false, fmt.Sprintf("The SAN DNS Name %s is a wildcard, which isn't allowed through the config flag", sanDNSName)
+		}
+
+		if strings.Contains(trimWildcard(sanDNSName), "*") {
+			return false, fmt.Sprintf("The SAN DNS Name %s contains a wildcard which isn't its leftmost label", sanDNSName)
 		}
 	}
 
-	return valid, reason, nil
+	return true, ""
 }
 
-// WhitelistedIPCheck verifies that the x509cr SAN IP Addresses are contained in the
-// set of ProviderSpecified IP addresses
-func (r *CertificateSigningRequestReconciler) WhitelistedIPCheck(csr *certificatesv1.CertificateSigningRequest, x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
+// fqdnAndShortnameCheck verifies that the SAN DNS names are precisely a short name and an FQDN
+// derived from it, e.g. node1 and node1.cluster.local. unless BypassHostnameCheck is set, the short
+// name must also be the one of the node. the FQDN is returned for the further verifications.
+func (r *CertificateSigningRequestReconciler) fqdnAndShortnameCheck(csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (fqdn string, valid bool, reason string) {
+	var shortnames, fqdns []string
+
+	for _, sanDNSName := range x509cr.DNSNames {
+		sanDNSName = NormalizeHostname(sanDNSName)
+		if strings.Contains(sanDNSName, ".") {
+			fqdns = append(fqdns, sanDNSName)
+		} else {
+			shortnames = append(shortnames, sanDNSName)
+		}
+	}
+
+	if len(shortnames) != 1 || len(fqdns) != 1 {
+		return "", false, "The SAN DNS Names of the x509 Cert Request must be exactly the node short name and its FQDN"
+	}
+
+	if !strings.HasPrefix(fqdns[0], shortnames[0]+".") {
+		return "", false, fmt.Sprintf("The SAN DNS Name %s is not the FQDN of the short name %s", fqdns[0], shortnames[0])
+	}
+
+	nodeShortname := strings.SplitN(NormalizeHostname(nodeNameOf(csr)), ".", 2)[0]
+	if shortnames[0] != nodeShortname && !r.BypassHostnameCheck {
+		return "", false, fmt.Sprintf("The SAN DNS Name %s is not the short name of the node", shortnames[0])
+	}
+
+	return fqdns[0], true, ""
+}
+
+// NormalizeHostname lowercases a DNS name and strips its trailing dot, for technically
+// equivalent names (e.g. Node1. and node1) to compare equal
+func NormalizeHostname(dnsName string) string {
+	return strings.ToLower(strings.TrimSuffix(dnsName, "."))
+}
+
+// trimWildcard strips the leading `*.` label of a wildcard DNS name
+func trimWildcard(dnsName string) string {
+	return strings.TrimPrefix(dnsName, "*.")
+}
+
+// regexCheck verifies that the SAN DNS names are prefixed by the node hostname and
+// allowed by the provider regex(es), unless the node is part of the node name allow-list.
+// the DNS names recorded in the Node annotation skip both verifications
+func (r *CertificateSigningRequestReconciler) regexCheck(csr *certificatesv1.CertificateSigningRequest, dnsNames []string,
+	annotatedNames map[string]struct{}) (valid bool, reason string) {
+	hostname := nodeNameOf(csr)
+	_, allowListed := r.NodeNameAllowSet[hostname]
+	normalizedHostname := NormalizeHostname(hostname)
+
+	for _, sanDNSName := range dnsNames {
+		sanDNSName = trimWildcard(sanDNSName)
+
+		// the Node annotations are writable by its kubelet: the annotated names are only exempted
+		// from the node name prefix, never from the provider regex nor the allowed DNS suffixes
+		_, annotated := annotatedNames[NormalizeHostname(sanDNSName)]
+
+		if valid = annotated || strings.HasPrefix(NormalizeHostname(sanDNSName), normalizedHostname); !valid && !r.BypassHostnameCheck {
+			reason = "The SAN DNS Name in the x509 CSR is not prefixed by the node name (hostname)"
+			return
+		}
+
+		// explicitly allow-listed nodes aren't subject to the provider regex
+		if valid = allowListed || r.matchesProviderRegex(sanDNSName) || r.hasAllowedDNSSuffix(sanDNSName); !valid {
+			reason = "The SAN DNS name in the x509 CR is not allowed by the Cloud provider regex, nor by the allowed DNS suffixes"
+			return
+		}
+	}
+
+	return true, ""
+}
+
+// shadowRegexCheck logs and counts the SAN DNS names which don't match the shadow regex, if set,
+// whatever the decision of the provider regex. the shadow regex never denies a CSR
+func (r *CertificateSigningRequestReconciler) shadowRegexCheck(ctx context.Context, dnsNames []string) {
+	r.rulesMu.RLock()
+	shadowRegexp := r.ShadowRegexp
+	r.rulesMu.RUnlock()
+
+	if shadowRegexp == nil {
+		return
+	}
+
+	for _, sanDNSName := range dnsNames {
+		if sanDNSName = trimWildcard(sanDNSName); !shadowRegexp(sanDNSName) {
+			log.FromContext(ctx).V(0).Info("The SAN DNS name doesn't match the shadow provider regex", "dnsName", sanDNSName)
+			shadowRegexMismatches.Inc()
+
+			return
+		}
+	}
+}
+
+// matchesProviderRegex returns true if the DNS name matches the DNS-specific regex when it is set,
+// or at least one of the provider regexes otherwise
+func (r *CertificateSigningRequestReconciler) matchesProviderRegex(dnsName string) bool {
+	r.rulesMu.RLock()
+	defer r.rulesMu.RUnlock()
+
+	return matchesDNSRegexps(r.DNSRegexp, r.ProviderRegexps, dnsName)
+}
+
+// matchesDNSRegexps returns true if the DNS name matches dnsRegexp when it is not nil, or at least
+// one of the provider regexps otherwise
+func matchesDNSRegexps(dnsRegexp func(string) bool, providerRegexps []func(string) bool, dnsName string) bool {
+	if dnsRegexp != nil {
+		return dnsRegexp(dnsName)
+	}
+
+	for _, match := range providerRegexps {
+		if match(dnsName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasAllowedDNSSuffix returns true if the DNS name ends with one of the allowed DNS suffixes, which
+// are compared case-insensitively and without trailing dot. a suffix matches at a label boundary
+// only, i.e. .example.com allows node1.example.com, but neither node1example.com nor example.com
+func (r *CertificateSigningRequestReconciler) hasAllowedDNSSuffix(dnsName string) bool {
+	dnsName = NormalizeHostname(dnsName)
+
+	for _, suffix := range r.AllowedDNSSuffixes {
+		if suffix = NormalizeHostname(suffix); !strings.HasPrefix(suffix, ".") {
+			suffix = "." + suffix
+		}
+
+		if strings.HasSuffix(dnsName, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSpecialIP returns true for the addresses which have no business in a kubelet serving
+// certificate, whatever the allowed IP prefixes are
+func isSpecialIP(ip netaddr.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// ipAllowed returns true if the IP address is part of the provider-specified IP prefixes
+// of its address family, falling back to the combined set of IP prefixes
+func (r *CertificateSigningRequestReconciler) ipAllowed(ip netaddr.IP) bool {
+	r.rulesMu.RLock()
+	defer r.rulesMu.RUnlock()
+
+	switch {
+	case ip.Is4() && r.ProviderIPv4Set != nil:
+		return r.ProviderIPv4Set.Contains(ip)
+	case ip.Is6() && r.ProviderIPv6Set != nil:
+		return r.ProviderIPv6Set.Contains(ip)
+	default:
+		return r.ProviderIPSet.Contains(ip)
+	}
+}
+
+// ipDenied returns true when the IP address is part of the denied IP prefixes
+func (r *CertificateSigningRequestReconciler) ipDenied(ip netaddr.IP) bool {
+	r.rulesMu.RLock()
+	defer r.rulesMu.RUnlock()
+
+	return r.ProviderDeniedIPSet != nil && r.ProviderDeniedIPSet.Contains(ip)
+}
+
+// nodeAddressIPSet returns the set limiting the addresses of the NodeAddressAnnotation, nil
+// when the annotation isn't set or no node address prefixes are configured
+func (r *CertificateSigningRequestReconciler) nodeAddressIPSet() *netaddr.IPSet {
+	if r.NodeAddressAnnotation == "" {
+		return nil
+	}
+
+	r.rulesMu.RLock()
+	defer r.rulesMu.RUnlock()
+
+	return r.NodeAddressIPSet
+}
+
+// WhitelistedIPCheck verifies that the x509cr doesn't contain more SAN IP Addresses than
+// allowed, and that they are contained in the set of ProviderSpecified IP addresses, or
+// with AllowPodCIDRIPs in the PodCIDRs of the Node object requesting the certificate
+func (r *CertificateSigningRequestReconciler) WhitelistedIPCheck(ctx context.Context, csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
+	defer observePhase(phaseIP, time.Now())
+
+	_, span := startSpan(ctx, phaseIP)
+	defer span.End()
+
+	if len(x509cr.IPAddresses) > r.AllowedIPAddresses {
+		return false, "The x509 Cert Request contains more IP addresses than allowed through the config flag", nil
+	}
+
+	// the IP addresses allowed for the Node are only retrieved for the SAN IP addresses outside of the allowed prefixes
+	var nodeIPs *netaddr.IPSet
+
 	sanIPAddrs := x509cr.IPAddresses
 	for _, ip := range sanIPAddrs {
 		ipa, ok := netaddr.FromStdIP(ip)
@@ -107,14 +460,93 @@ func (r *CertificateSigningRequestReconciler) WhitelistedIPCheck(csr *certificat
 			return false, fmt.Sprintf("Error while parsing x509 CR IP address %s, denying the CSR", ip), nil
 		}
 
-		if !r.ProviderIPSet.Contains(ipa) {
+		if r.RejectSpecialIPs && isSpecialIP(ipa) {
+			return false, fmt.Sprintf("The SAN IP address %s is a loopback, link-local, multicast or unspecified address, "+
+				"denying the CSR.", ipa), nil
+		}
+
+		if !r.ipAllowed(ipa) && (r.AllowPodCIDRIPs || r.nodeAddressIPSet() != nil) && nodeIPs == nil {
+			if nodeIPs, err = r.nodeAllowedIPs(ctx, csr); err != nil {
+				return false, "Unable to retrieve the Node object of the CSR requestor", err
+			}
+		}
+
+		if !r.ipAllowed(ipa) && (nodeIPs == nil || !nodeIPs.Contains(ipa) || r.ipDenied(ipa)) {
 			return false,
 				fmt.Sprintf(
-					"One of the SAN IP addresses, %s, is not part"+
-						"of the allowed IP Prefixes/Subnets, denying the CSR.", ipa),
+					"One of the SAN IP addresses, %s, is not part "+
+						"of the allowed IP Prefixes/Subnets (or is part of the denied ones), denying the CSR.", ipa),
 				nil
 		}
 	}
 
 	return true, reason, nil
 }
+
+// reverseDNSCheck performs a reverse (PTR) lookup of every SAN IP address and verifies
+// that one of the resulting names is also a SAN DNS name of the x509 CR
+func (r *CertificateSigningRequestReconciler) reverseDNSCheck(dnsCtx context.Context, x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
+	addrResolver, ok := r.DNSResolver.(AddrResolver)
+	if !ok {
+		return false, "The configured DNS resolver doesn't support reverse lookups", fmt.Errorf("DNS resolver %T doesn't implement LookupAddr", r.DNSResolver)
+	}
+
+	if len(x509cr.IPAddresses) == 0 {
+		return false, "The x509 CR doesn't contain any SAN IP address to reverse-resolve, the SAN DNS names can't be verified", nil
+	}
+
+	for _, ip := range x509cr.IPAddresses {
+		names, err := addrResolver.LookupAddr(dnsCtx, ip.String())
+
+		var dnsErr *net.DNSError
+		if dnsCtx.Err() != nil || (errors.As(err, &dnsErr) && dnsErr.IsTimeout) {
+			countInterruptedLookup(dnsCtx)
+			return false, fmt.Sprintf("The reverse resolution of the SAN IP address %s %s", ip, interruptedLookup(dnsCtx)), lookupError(dnsCtx, err)
+		}
+
+		if err != nil || len(names) == 0 {
+			dnsFailures.WithLabelValues(lookupFailure(err)).Inc()
+			return false, fmt.Sprintf("The SAN IP address %s could not be reverse-resolved, denying the CSR", ip), nil
+		}
+
+		if ptrMatchesDNSName(names, x509cr.DNSNames) {
+			continue
+		}
+
+		if valid, reason = r.dnsMismatch(dnsCtx, fmt.Sprintf("None of the names the SAN IP address %s reverse-resolves to "+
+			"is part of the SAN DNS names, denying the CSR", ip)); !valid {
+			return valid, reason, nil
+		}
+	}
+
+	return true, "", nil
+}
+
+// dnsMismatch denies the CSR whose resolved DNS records don't match its SAN IP addresses or DNS
+// names, unless DNSMismatchWarnOnly is set: the mismatch is then only logged and counted by the
+// shadow DNS mismatch metric, and the CSR goes on with the other SAN IP addresses and checks
+func (r *CertificateSigningRequestReconciler) dnsMismatch(ctx context.Context, reason string) (valid bool, _ string) {
+	if !r.DNSMismatchWarnOnly {
+		dnsFailures.WithLabelValues(dnsFailureMismatch).Inc()
+		return false, reason
+	}
+
+	log.FromContext(ctx).V(0).Info("Ignoring the DNS mismatch, as dns-mismatch-warn-only is set", "mismatch", reason)
+	shadowDNSMismatches.Inc()
+
+	return true, ""
+}
+
+func ptrMatchesDNSName(ptrNames, sanDNSNames []string) bool {
+	for _, ptrName := range ptrNames {
+		ptrName = strings.TrimSuffix(ptrName, ".")
+
+		for _, sanDNSName := range sanDNSNames {
+			if strings.EqualFold(ptrName, trimWildcard(sanDNSName)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// RegexSelfTest lists sample SAN DNS names the provider regexes are expected to match, and
+// others they are expected not to match. it is parsed from the YAML RegexSelfTestFile, e.g.
+//
+//	match:
+//	  - node1.example.com
+//	no-match:
+//	  - example.com
+type RegexSelfTest struct {
+	Match   []string `json:"match"`
+	NoMatch []string `json:"no-match"`
+}
+
+// ReadRegexSelfTest reads and parses a regex self-test file
+func ReadRegexSelfTest(path string) (*RegexSelfTest, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var selfTest RegexSelfTest
+	if err := yaml.UnmarshalStrict(data, &selfTest); err != nil {
+		return nil, fmt.Errorf("unable to parse the regex self-test file %s: %w", path, err)
+	}
+
+	return &selfTest, nil
+}
+
+// Run evaluates the provider regexes of rules against the samples, like the SAN DNS names are:
+// a sample matches the DNS regex when it is set, or any of the provider regexes otherwise. the samples disagreeing with their expectation are returned as an error
+func (s *RegexSelfTest) Run(rules *ProviderRules) error {
+	var unexpected []string
+
+	for _, sample := range s.Match {
+		if !rules.regexMatches(sample) {
+			unexpected = append(unexpected, sample+" doesn't match")
+		}
+	}
+
+	for _, sample := range s.NoMatch {
+		if rules.regexMatches(sample) {
+			unexpected = append(unexpected, sample+" matches")
+		}
+	}
+
+	if len(unexpected) > 0 {
+		return fmt.Errorf("the provider regex self-test failed: %s", strings.Join(unexpected, ", "))
+	}
+
+	return nil
+}
+
+// regexMatches returns true when the DNS name matches the rules, see matchesDNSRegexps
+func (rules *ProviderRules) regexMatches(dnsName string) bool {
+	return matchesDNSRegexps(rules.DNSRegexp, rules.Regexps, dnsName)
+}
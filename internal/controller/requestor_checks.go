@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// RequestorAccessCheck verifies, when VerifyRequestorAccess is set, the identity the API server
+// authenticated when the CSR was created rather than the x509 CR subject: the requestor groups must
+// include system:nodes, and a SubjectAccessReview must confirm the requestor is still allowed to
+// create CSRs. a failing SubjectAccessReview is returned as an error, for the CSR to be processed again.
+// the identity is reviewed rather than authenticated again with a TokenReview: the API server records
+// the authenticated user, groups and extra in the CSR spec, but not the bearer token of the requestor.
+func (r *CertificateSigningRequestReconciler) RequestorAccessCheck(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest) (valid bool, reason string, err error) {
+	if !r.VerifyRequestorAccess {
+		return true, "", nil
+	}
+
+	if !containsString(csr.Spec.Groups, "system:nodes") {
+		return false, fmt.Sprintf("The groups of the CSR requestor %s don't include system:nodes", csr.Spec.Username), nil
+	}
+
+	allowed, err := r.requestorAllowed(ctx, csr, "")
+	if err != nil {
+		return false, "Unable to review the access of the CSR requestor", err
+	}
+
+	if !allowed {
+		return false, fmt.Sprintf("The CSR requestor %s is not allowed to create CSRs anymore", csr.Spec.Username), nil
+	}
+
+	return true, "", nil
+}
+
+// ClientRequestorCheck authorizes the requestor of a kube-apiserver-client-kubelet CSR like the approver of
+// the kube-controller-manager does: the requestor groups must include system:bootstrappers or system:nodes,
+// and a SubjectAccessReview must allow the requestor to create the certificatesigningrequests/selfnodeclient
+// subresource when renewing its own client certificate (i.e. the x509 CR CommonName is the requestor
+// username), or the certificatesigningrequests/nodeclient subresource. a failing SubjectAccessReview is
+// returned as an error, for the CSR to be processed again.
+func (r *CertificateSigningRequestReconciler) ClientRequestorCheck(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest, x509cr *x509.CertificateRequest) (valid bool, reason string, err error) {
+	if !containsString(csr.Spec.Groups, "system:bootstrappers") && !containsString(csr.Spec.Groups, "system:nodes") {
+		return false, fmt.Sprintf("The groups of the CSR requestor %s include neither system:bootstrappers nor system:nodes",
+			csr.Spec.Username), nil
+	}
+
+	if r.SkipClientRequestorReview {
+		return true, "", nil
+	}
+
+	subresources := []string{"nodeclient"}
+	if csr.Spec.Username == x509cr.Subject.CommonName {
+		subresources = []string{"selfnodeclient", "nodeclient"}
+	}
+
+	for _, subresource := range subresources {
+		allowed, err := r.requestorAllowed(ctx, csr, subresource)
+		if err != nil {
+			return false, "Unable to review the access of the CSR requestor", err
+		}
+
+		if allowed {
+			return true, "", nil
+		}
+	}
+
+	return false, fmt.Sprintf("The CSR requestor %s is not allowed to request a kubelet client certificate "+
+		"(certificatesigningrequests subresources %v)", csr.Spec.Username, subresources), nil
+}
+
+// requestorAllowed creates a SubjectAccessReview of the CSR requestor, as recorded in the CSR spec,
+// for the creation of CSRs or, when subresource is not empty, of the given CSR subresource
+func (r *CertificateSigningRequestReconciler) requestorAllowed(ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest, subresource string) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(csr.Spec.Extra))
+	for key, value := range csr.Spec.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+
+	if r.ClientSet == nil {
+		return false, errNoClientSet
+	}
+
+	ctx, span := startSpan(ctx, "CreateSubjectAccessReview")
+	defer span.End()
+
+	review, err := r.ClientSet.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   csr.Spec.Username,
+			Groups: csr.Spec.Groups,
+			UID:    csr.Spec.UID,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:       certificatesv1.GroupName,
+				Resource:    "certificatesigningrequests",
+				Subresource: subresource,
+				Verb:        "create",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return review.Status.Allowed, nil
+}
+
+// containsString returns true when s is one of the values
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+
+	return false
+}
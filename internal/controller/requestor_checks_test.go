@@ -0,0 +1,39 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tj/assert"
+	certificatesv1 "k8s.io/api/certificates/v1"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestRequestorAccessCheckRequiresSystemNodesGroup(t *testing.T) {
+	r := controller.CertificateSigningRequestReconciler{Config: controller.Config{VerifyRequestorAccess: true}}
+
+	valid, reason, err := r.RequestorAccessCheck(context.Background(), &certificatesv1.CertificateSigningRequest{
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username: "system:node:node1",
+			Groups:   []string{"system:authenticated"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.False(t, valid)
+	assert.Contains(t, reason, "don't include system:nodes")
+}
+
+func TestRequestorAccessCheckWithoutClientSet(t *testing.T) {
+	r := controller.CertificateSigningRequestReconciler{Config: controller.Config{VerifyRequestorAccess: true}}
+
+	// the access can't be reviewed, the CSR is processed again rather than denied
+	valid, _, err := r.RequestorAccessCheck(context.Background(), &certificatesv1.CertificateSigningRequest{
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username: "system:node:node1",
+			Groups:   []string{"system:nodes", "system:authenticated"},
+		},
+	})
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// resyncTimeout bounds the enqueuing of the Pending CSRs by the /resync endpoint
+const resyncTimeout = 10 * time.Second
+
+// ResyncResponse is the body of the /resync endpoint response
+type ResyncResponse struct {
+	Enqueued int `json:"enqueued"`
+}
+
+// ResyncHandler enqueues every Pending CSR of the handled signers for reconciliation, e.g.
+// after a config reload or the fix of a DNS issue, and responds with the number of enqueued
+// CSRs. only POST requests bearing the ResyncToken are served, the endpoint is disabled
+// when no token is configured. the replicas which aren't the leader respond with a 503, since
+// their controller doesn't run.
+func (r *CertificateSigningRequestReconciler) ResyncHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if r.ResyncToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(r.ResyncToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !r.isElected() {
+		http.Error(w, "this replica isn't the leader, its controller doesn't process the CSRs", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), resyncTimeout)
+	defer cancel()
+
+	pendingCSRs, err := r.listPendingCSRs(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var response ResyncResponse
+
+	for _, csr := range pendingCSRs {
+		select {
+		case r.resync <- event.GenericEvent{Object: csr}:
+			response.Enqueued++
+		case <-ctx.Done():
+			http.Error(w, "timed out while enqueuing the CSRs", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	log.FromContext(ctx).V(0).Info("Pending CSRs enqueued for reconciliation", "enqueued", response.Enqueued)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// electionNotifier returns the Runnable closing the elected channel, along with the controllers,
+// i.e. once the leader election (if enabled) is won
+func (r *CertificateSigningRequestReconciler) electionNotifier() manager.Runnable {
+	return manager.RunnableFunc(func(context.Context) error {
+		close(r.elected)
+		return nil
+	})
+}
+
+// isElected returns true once the controller of this replica started, i.e. when it is the leader
+// or the leader election is disabled
+func (r *CertificateSigningRequestReconciler) isElected() bool {
+	select {
+	case <-r.elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// listPendingCSRs returns the CSRs of the handled signers which are neither approved nor denied yet
+func (r *CertificateSigningRequestReconciler) listPendingCSRs(ctx context.Context) ([]*certificatesv1.CertificateSigningRequest, error) {
+	var csrList certificatesv1.CertificateSigningRequestList
+	if err := r.Client.List(ctx, &csrList); err != nil {
+		return nil, err
+	}
+
+	var pendingCSRs []*certificatesv1.CertificateSigningRequest
+
+	for i := range csrList.Items {
+		csr := &csrList.Items[i]
+		if approved, denied := r.approvalCondition(csr); !approved && !denied && r.handlesSigner(csr.Spec.SignerName) {
+			pendingCSRs = append(pendingCSRs, csr)
+		}
+	}
+
+	return pendingCSRs, nil
+}
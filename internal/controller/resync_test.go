@@ -0,0 +1,50 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestResyncHandler(t *testing.T) {
+	csrController.ResyncToken = "s3cr3t"
+	defer func() { csrController.ResyncToken = "" }()
+
+	recorder := httptest.NewRecorder()
+	csrController.ResyncHandler(recorder, httptest.NewRequest(http.MethodPost, "/resync", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	csrController.ResyncHandler(recorder, httptest.NewRequest(http.MethodGet, "/resync", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/resync", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+
+	recorder = httptest.NewRecorder()
+	csrController.ResyncHandler(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var response controller.ResyncResponse
+	require.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.GreaterOrEqual(t, response.Enqueued, 0)
+}
+
+func TestResyncHandlerNotLeader(t *testing.T) {
+	// SetupWithManager wasn't called, like the controller of a replica which lost the leader election
+	r := &controller.CertificateSigningRequestReconciler{Config: controller.Config{ResyncToken: "s3cr3t"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/resync", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+
+	recorder := httptest.NewRecorder()
+	r.ResyncHandler(recorder, req)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "isn't the leader")
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// maxRecordedErrors bounds the number of errors recorded per CSR, for the dead-letter log
+const maxRecordedErrors = 10
+
+// retryCounter keeps track of the consecutive failed reconciliations of each CSR, and of
+// their most recent errors
+type retryCounter struct {
+	mu      sync.Mutex
+	entries map[string]*retryEntry
+}
+
+type retryEntry struct {
+	count  int
+	errors []string
+}
+
+// inc records the error, and returns the number of failed reconciliations of the CSR
+// along with their most recent errors
+func (c *retryCounter) inc(csrName string, err error) (int, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*retryEntry)
+	}
+
+	entry, ok := c.entries[csrName]
+	if !ok {
+		entry = &retryEntry{}
+		c.entries[csrName] = entry
+	}
+
+	entry.count++
+
+	entry.errors = append(entry.errors, err.Error())
+	if len(entry.errors) > maxRecordedErrors {
+		entry.errors = entry.errors[len(entry.errors)-maxRecordedErrors:]
+	}
+
+	return entry.count, append([]string(nil), entry.errors...)
+}
+
+func (c *retryCounter) reset(csrName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, csrName)
+}
+
+// pendingError is returned by the checks which intentionally leave the CSR Pending until
+// a condition outside of the CSR is met, e.g. until its Node is Ready
+type pendingError struct {
+	reason string
+}
+
+func (e *pendingError) Error() string {
+	return e.reason
+}
+
+func isPending(err error) bool {
+	var pending *pendingError
+	return errors.As(err, &pending)
+}
+
+func (r *CertificateSigningRequestReconciler) pendingRequeueInterval() time.Duration {
+	if r.PendingRequeueInterval <= 0 {
+		return DefaultPendingRequeueInterval
+	}
+
+	return r.PendingRequeueInterval
+}
+
+// jittered spreads the delay uniformly within ±RequeueJitter of its value, so that the CSRs left
+// Pending at once (e.g. during a rollout) aren't all processed again at the same time
+func (r *CertificateSigningRequestReconciler) jittered(delay time.Duration) time.Duration {
+	if r.RequeueJitter <= 0 {
+		return delay
+	}
+
+	//nolint:gosec // the jitter spreads the load, it doesn't need a cryptographically secure random number
+	return time.Duration(float64(delay) * (1 + r.RequeueJitter*(2*rand.Float64()-1)))
+}
+
+// requeueOnError hands the transient error over to controller-runtime, which processes
+// the CSR again with an exponential backoff. Once the CSR failed more than MaxRetries
+// times in a row, it is given up on and left Pending, until it gets updated. the CSR is
+// then recorded in the dead-letter log, along with its most recent errors.
+// a pendingError is instead retried after the PendingRequeueInterval, jittered by the RequeueJitter.
+func (r *CertificateSigningRequestReconciler) requeueOnError(l logr.Logger, csrName string, err error) (ctrl.Result, error) {
+	if isPending(err) {
+		// not a failure: the CSR is processed again after a (jittered) delay, without counting as a retry
+		delay := r.jittered(r.pendingRequeueInterval())
+		l.V(0).Info("Leaving the CSR Pending. Reason: "+err.Error(), "requeueAfter", delay)
+		r.retries.reset(csrName)
+
+		return ctrl.Result{RequeueAfter: delay}, nil
+	}
+
+	if errors.Is(err, context.Canceled) {
+		// the controller is stopping: the CSR is processed again once it (or another replica) runs
+		l.V(1).Info("The reconciliation of the CSR was cancelled", "csr_name", csrName)
+
+		return ctrl.Result{}, err
+	}
+
+	retries, errs := r.retries.inc(csrName, err)
+	if r.MaxRetries > 0 && retries > r.MaxRetries {
+		l.WithName("dead-letter").Error(err, "Giving up on the CSR after too many failed attempts, leaving it Pending",
+			"csr_name", csrName, "retries", retries-1, "errors", errs)
+		retriesExhausted.Inc()
+		r.retries.reset(csrName)
+
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, err
+}
@@ -0,0 +1,60 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestPendingRequeueJitter(t *testing.T) {
+	csr := createCsr(t, CsrParams{csrName: "requeue-jitter", nodeName: "node-jitter", dnsName: "node-jitter.test.ch"})
+	// the Node isn't Ready yet, the CSR is left Pending
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-jitter"}}
+
+	for _, jitter := range []float64{0, 0.2} {
+		r := &controller.CertificateSigningRequestReconciler{
+			Client: fake.NewClientBuilder().WithObjects(node).Build(),
+			Config: controller.Config{
+				RegexStr:               `^[\w-]*\.test\.ch$`,
+				IPPrefixesStr:          "192.168.0.0/16",
+				MaxExpirationSeconds:   367 * 24 * 3600,
+				AllowedDNSNames:        1,
+				BypassDNSResolution:    true,
+				RequireNodeReady:       true,
+				PendingRequeueInterval: 10 * time.Second,
+				RequeueJitter:          jitter,
+			},
+			Approver: &fakeCSRApprover{csrs: map[string]*certificatesv1.CertificateSigningRequest{csr.Name: &csr}},
+		}
+		rules, err := controller.CompileProviderRules(&r.Config)
+		require.Nil(t, err)
+		r.SetProviderRules(rules)
+
+		delays := make(map[time.Duration]struct{})
+
+		for i := 0; i < 20; i++ {
+			res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: csr.Name}})
+			require.Nil(t, err)
+			assert.InDelta(t, 10*time.Second, res.RequeueAfter, jitter*float64(10*time.Second)+1)
+
+			delays[res.RequeueAfter] = struct{}{}
+		}
+
+		if jitter == 0 {
+			assert.Len(t, delays, 1)
+		} else {
+			assert.Greater(t, len(delays), 1, "the requeue delays are jittered")
+		}
+	}
+}
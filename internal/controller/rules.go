@@ -0,0 +1,25 @@
+package controller
+
+// names of the validation rules a CSR can fail, used to label the denial metrics
+const (
+	ruleParse        = "parse"
+	ruleSignature    = "signature"
+	ruleStale        = "stale"
+	ruleUsername     = "username"
+	ruleSAN          = "san"
+	ruleCommonName   = "commonname"
+	ruleOrganization = "organization"
+	ruleRequestor    = "requestor"
+	ruleKey          = "key"
+	ruleUsage        = "usage"
+	ruleDNS          = "dns"
+	ruleIPPrefix     = "ip-prefix"
+	ruleNodeIP       = "node-ip"
+	ruleNodeDNS      = "node-dns"
+	ruleNodeReady    = "node-ready"
+	ruleInstanceID   = "instance-id"
+	ruleExpiration   = "expiration"
+	ruleProvider     = "provider"
+	ruleCELPolicy    = "cel-policy"
+	ruleWebhook      = "webhook"
+)
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"inet.af/netaddr"
+)
+
+// DuplicateSANCheck denies the x509 CRs listing the same DNS name (compared once normalized)
+// or the same IP address more than once in their SAN
+func (r *CertificateSigningRequestReconciler) DuplicateSANCheck(x509cr *x509.CertificateRequest) (valid bool, reason string) {
+	if !r.RejectDuplicateSANs {
+		return true, ""
+	}
+
+	dnsNames := make(map[string]struct{}, len(x509cr.DNSNames))
+
+	for _, dnsName := range x509cr.DNSNames {
+		normalized := NormalizeHostname(dnsName)
+		if _, ok := dnsNames[normalized]; ok {
+			return false, fmt.Sprintf("The x509 Cert Request SAN contains the DNS name %s more than once", dnsName)
+		}
+
+		dnsNames[normalized] = struct{}{}
+	}
+
+	ipAddresses := make(map[netaddr.IP]struct{}, len(x509cr.IPAddresses))
+
+	for _, ip := range x509cr.IPAddresses {
+		// the IPv4-mapped IPv6 addresses are unmapped, and compared with their IPv4 form
+		ipa, ok := netaddr.FromStdIP(ip)
+		if !ok {
+			continue
+		}
+
+		if _, ok := ipAddresses[ipa]; ok {
+			return false, fmt.Sprintf("The x509 Cert Request SAN contains the IP address %s more than once", ipa)
+		}
+
+		ipAddresses[ipa] = struct{}{}
+	}
+
+	return true, ""
+}
+
+// UnexpectedSANTypesCheck denies the x509 CRs whose SAN contains URIs or email addresses, which have
+// no business in a kubelet serving certificate, when RejectUnexpectedSANTypes is set
+func (r *CertificateSigningRequestReconciler) UnexpectedSANTypesCheck(x509cr *x509.CertificateRequest) (valid bool, reason string) {
+	if !r.RejectUnexpectedSANTypes {
+		return true, ""
+	}
+
+	if len(x509cr.URIs) > 0 {
+		return false, fmt.Sprintf("The x509 Cert Request SAN contains the URI %s, only DNS names and IP addresses are allowed", x509cr.URIs[0])
+	}
+
+	if len(x509cr.EmailAddresses) > 0 {
+		return false, fmt.Sprintf("The x509 Cert Request SAN contains the email address %s, only DNS names and IP addresses are allowed",
+			x509cr.EmailAddresses[0])
+	}
+
+	return true, ""
+}
+
+// TotalSANsCheck denies the x509 CRs whose SAN contains more than MaxTotalSANs entries, whatever
+// their types, on top of the AllowedDNSNames and AllowedIPAddresses limits. 0 disables the check
+func (r *CertificateSigningRequestReconciler) TotalSANsCheck(x509cr *x509.CertificateRequest) (valid bool, reason string) {
+	if r.MaxTotalSANs == 0 {
+		return true, ""
+	}
+
+	total := len(x509cr.DNSNames) + len(x509cr.IPAddresses) + len(x509cr.URIs) + len(x509cr.EmailAddresses)
+	if total > r.MaxTotalSANs {
+		return false, fmt.Sprintf("The x509 Cert Request SAN contains %d entries, more than the %d allowed", total, r.MaxTotalSANs)
+	}
+
+	return true, ""
+}
@@ -0,0 +1,90 @@
+package controller_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestNormalizeHostname(t *testing.T) {
+	for dnsName, normalized := range map[string]string{
+		"Node1.":             "node1",
+		"node1":              "node1",
+		"NODE1.example.com.": "node1.example.com",
+	} {
+		assert.Equal(t, normalized, controller.NormalizeHostname(dnsName), dnsName)
+	}
+}
+
+func TestDuplicateSANCheckNormalizesDNSNames(t *testing.T) {
+	r := controller.CertificateSigningRequestReconciler{Config: controller.Config{RejectDuplicateSANs: true}}
+
+	valid, reason := r.DuplicateSANCheck(&x509.CertificateRequest{DNSNames: []string{"node1.example.com", "NODE1.example.com."}})
+	assert.False(t, valid)
+	assert.Contains(t, reason, "more than once")
+
+	valid, _ = r.DuplicateSANCheck(&x509.CertificateRequest{DNSNames: []string{"node1", "node1.example.com"}})
+	assert.True(t, valid)
+}
+
+func TestUnexpectedSANTypesCheck(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	uri, err := url.Parse("spiffe://cluster.local/ns/default/sa/default")
+	require.Nil(t, err)
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "system:node:node1", Organization: []string{"system:nodes"}},
+		DNSNames: []string{"node1.example.com"},
+		URIs:     []*url.URL{uri},
+	}, key)
+	require.Nil(t, err)
+
+	x509cr, err := controller.ParseCSR(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+	require.Nil(t, err)
+
+	r := controller.CertificateSigningRequestReconciler{}
+	valid, _ := r.UnexpectedSANTypesCheck(x509cr)
+	assert.True(t, valid, "the check is opt-in")
+
+	r.RejectUnexpectedSANTypes = true
+	valid, reason := r.UnexpectedSANTypesCheck(x509cr)
+	assert.False(t, valid)
+	assert.Contains(t, reason, "spiffe://cluster.local")
+
+	valid, reason = r.UnexpectedSANTypesCheck(&x509.CertificateRequest{EmailAddresses: []string{"node1@example.com"}})
+	assert.False(t, valid)
+	assert.Contains(t, reason, "node1@example.com")
+
+	valid, _ = r.UnexpectedSANTypesCheck(&x509.CertificateRequest{DNSNames: []string{"node1.example.com"}})
+	assert.True(t, valid)
+}
+
+func TestTotalSANsCheck(t *testing.T) {
+	uri, err := url.Parse("spiffe://cluster.local/ns/default/sa/default")
+	require.Nil(t, err)
+
+	x509cr := &x509.CertificateRequest{
+		DNSNames:    []string{"node1.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("192.168.0.1")},
+		URIs:        []*url.URL{uri},
+	}
+
+	for maxTotalSANs, valid := range map[int]bool{0: true, 2: false, 3: true} {
+		r := controller.CertificateSigningRequestReconciler{Config: controller.Config{MaxTotalSANs: maxTotalSANs}}
+
+		ok, reason := r.TotalSANsCheck(x509cr)
+		assert.Equal(t, valid, ok, "max total SANs %d: %s", maxTotalSANs, reason)
+	}
+}
@@ -19,6 +19,7 @@ package controller_test
 import (
 	"context"
 	"encoding/pem"
+	"fmt"
 	"net"
 	"os"
 	"testing"
@@ -37,6 +38,7 @@ import (
 	"github.com/thanhpk/randstr"
 	capiv1 "k8s.io/api/certificates/v1"
 	certificates_v1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -87,10 +89,12 @@ type CsrParams struct {
 	csrName           string
 	commonName        string
 	dnsName           string
+	extraDNSNames     []string
 	nodeName          string
 	username          string
 	ipAddresses       []net.IP
 	expirationSeconds int32
+	organizations     []string
 }
 
 var (
@@ -132,10 +136,14 @@ func createCsr(t *testing.T, params CsrParams) certificates_v1.CertificateSignin
 		csr.Spec.ExpirationSeconds = &params.expirationSeconds
 	}
 
+	if params.organizations == nil {
+		params.organizations = []string{"system:nodes"}
+	}
+
 	_, priv, _ := ed25519.GenerateKey(rand.Reader)
 	x509RequestTemplate := x509.CertificateRequest{
 		Subject: pkix.Name{
-			Organization: []string{"system:nodes"},
+			Organization: params.organizations,
 			CommonName:   params.commonName,
 		},
 		IPAddresses: params.ipAddresses,
@@ -143,6 +151,7 @@ func createCsr(t *testing.T, params CsrParams) certificates_v1.CertificateSignin
 	if len(params.dnsName) > 0 {
 		x509RequestTemplate.DNSNames = []string{params.dnsName}
 	}
+	x509RequestTemplate.DNSNames = append(x509RequestTemplate.DNSNames, params.extraDNSNames...)
 
 	x509Request, _ := x509.CreateCertificateRequest(rand.Reader, &x509RequestTemplate, priv)
 	pemRequest := pem.EncodeToMemory(&pem.Block{
@@ -154,6 +163,54 @@ func createCsr(t *testing.T, params CsrParams) certificates_v1.CertificateSignin
 	return csr
 }
 
+// createNode creates a Node object with the given name, labels, and status
+func createNode(t *testing.T, name string, labels map[string]string, status corev1.NodeStatus) *corev1.Node {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+	}
+	err := k8sClient.Create(testContext, node)
+	if err != nil {
+		t.Fatalf("Could not create the Node %s. Error message: %v", name, err)
+	}
+
+	node.Status = status
+	err = k8sClient.Status().Update(testContext, node)
+	if err != nil {
+		t.Fatalf("Could not update the status of the Node %s. Error message: %v", name, err)
+	}
+
+	// wait for the controller cache to know about the up-to-date Node
+	for i := 0; i < 40; i++ {
+		var cachedNode corev1.Node
+		err = csrController.Client.Get(testContext, client.ObjectKeyFromObject(node), &cachedNode)
+		if err == nil && cachedNode.ResourceVersion == node.ResourceVersion {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return node
+}
+
+// registerDNSZone mocks the DNS zone of a node, resolving nodeName.test.ch to its IP addresses
+func registerDNSZone(nodeName string, ipAddresses []net.IP) {
+	zone := mockdns.Zone{}
+	for _, ip := range ipAddresses {
+		if ip.To4() != nil {
+			zone.A = append(zone.A, ip.String())
+		} else {
+			zone.AAAA = append(zone.AAAA, ip.String())
+		}
+	}
+	dnsResolver.Zones[nodeName+".test.ch."] = zone
+}
+
+// registerPTRZone mocks the reverse DNS zone of an IPv4 address, resolving it to names
+func registerPTRZone(ip net.IP, names ...string) {
+	ip4 := ip.To4()
+	arpa := fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0])
+	dnsResolver.Zones[arpa] = mockdns.Zone{PTR: names}
+}
+
 func createControlPlaneUser(t *testing.T, username string, groups []string) (*rest.Config, *clientset.Clientset, error) {
 	userInfo := envtest.User{Name: username, Groups: groups}
 	userCfg, err := testEnv.ControlPlane.AddUser(userInfo, cfg)
@@ -206,6 +263,7 @@ func packageSetup() {
 		RegexStr:               `^[\w-]*\.test\.ch$`,
 		MaxExpirationSeconds:   367 * 24 * 3600,
 		AllowedDNSNames:        3,
+		AllowedIPAddresses:     3,
 		K8sConfig:              cfg,
 		IgnoreNonSystemNodeCsr: true,
 		DNSResolver:            &dnsResolver,
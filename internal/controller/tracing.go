@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/postfinance/kubelet-csr-approver/internal/controller"
+
+// startSpan starts a span named after the reconcile step with the global tracer provider,
+// which is a no-op unless tracing has been configured at startup
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// warmupStarter returns the Runnable starting the StartupWarmupDelay, along with the
+// controllers, i.e. once the leader election (if enabled) is won
+func (r *CertificateSigningRequestReconciler) warmupStarter() manager.Runnable {
+	return manager.RunnableFunc(func(context.Context) error {
+		r.warmupStart()
+		return nil
+	})
+}
+
+func (r *CertificateSigningRequestReconciler) warmupStart() time.Time {
+	r.startOnce.Do(func() { r.startedAt = time.Now() })
+
+	return r.startedAt
+}
+
+// warmupRemaining returns the time left until the StartupWarmupDelay following the controller
+// start elapsed, during which the CSRs relying on the Node objects are deferred: the Nodes may
+// be missing from the informer cache right after a restart. 0 is returned once it elapsed, or
+// when none of the configured checks looks the Nodes up
+func (r *CertificateSigningRequestReconciler) warmupRemaining() time.Duration {
+	if r.StartupWarmupDelay <= 0 || !r.usesNodes() {
+		return 0
+	}
+
+	if remaining := r.StartupWarmupDelay - time.Since(r.warmupStart()); remaining > 0 {
+		return remaining
+	}
+
+	return 0
+}
+
+// usesNodes returns true when one of the configured features looks the CSR Node up
+func (r *CertificateSigningRequestReconciler) usesNodes() bool {
+	return (r.NodeSelector != nil && !r.NodeSelector.Empty()) ||
+		(r.PriorityNodeSelector != nil && !r.PriorityNodeSelector.Empty()) ||
+		r.VerifyNodeIPAddresses || r.VerifyNodeDNSNames || r.VerifyCloudInstanceID || r.RequireNodeReady ||
+		r.AllowPodCIDRIPs || r.RelaxedRenewalMode || r.DNSNameNodeAnnotation != "" ||
+		r.NodeAddressAnnotation != "" || r.OverridesConfigMap != ""
+}
@@ -0,0 +1,51 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tj/assert"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/postfinance/kubelet-csr-approver/internal/controller"
+)
+
+func TestStartupWarmupDelay(t *testing.T) {
+	for _, requireNodeReady := range []bool{false, true} {
+		csr := createCsr(t, CsrParams{csrName: "warmup", nodeName: "node-warmup", dnsName: "node-warmup.test.ch"})
+		approver := &fakeCSRApprover{csrs: map[string]*certificatesv1.CertificateSigningRequest{csr.Name: &csr}}
+
+		r := &controller.CertificateSigningRequestReconciler{
+			Config: controller.Config{
+				RegexStr:             `^[\w-]*\.test\.ch$`,
+				IPPrefixesStr:        "192.168.0.0/16",
+				MaxExpirationSeconds: 367 * 24 * 3600,
+				AllowedDNSNames:      1,
+				BypassDNSResolution:  true,
+				RequireNodeReady:     requireNodeReady,
+				StartupWarmupDelay:   time.Hour,
+			},
+			Approver: approver,
+		}
+		rules, err := controller.CompileProviderRules(&r.Config)
+		require.Nil(t, err)
+		r.SetProviderRules(rules)
+
+		res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: csr.Name}})
+		require.Nil(t, err)
+
+		if requireNodeReady {
+			// the Node lookup is deferred until the delay elapsed
+			assert.Empty(t, approver.approved)
+			assert.Empty(t, approver.denied)
+			assert.Greater(t, res.RequeueAfter, 59*time.Minute)
+		} else {
+			// none of the checks relies on the Node objects
+			assert.Equal(t, []string{csr.Name}, approver.approved)
+		}
+	}
+}